@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/thenilesh/url-shortner/metrics"
+	"github.com/thenilesh/url-shortner/rest"
+	"github.com/thenilesh/url-shortner/store"
+	"github.com/thenilesh/url-shortner/svc"
+)
+
+// App wires the stores, metrics and URLShortner as configured, and runs
+// them either as the server or for a command
+type App struct {
+	config      Config
+	log         *logrus.Logger
+	redis       *redis.Client
+	metrics     metrics.Metrics
+	urlShortner svc.URLShortner
+}
+
+// New connects to redis and builds the App, Start must be called before
+// using it
+func New(config Config, log *logrus.Logger) (*App, error) {
+	redis, err := connectRedis(config)
+	if err != nil {
+		return nil, err
+	}
+	a := &App{config: config, log: log, redis: redis}
+	if a.metrics, err = a.newMetrics(); err != nil {
+		redis.Close()
+		return nil, err
+	}
+	if a.urlShortner, err = a.newURLShortner(); err != nil {
+		redis.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *App) URLShortner() svc.URLShortner {
+	return a.urlShortner
+}
+
+func (a *App) Metrics() metrics.Metrics {
+	return a.metrics
+}
+
+// IndexAliases indexes the mixed-case short paths created while
+// case-insensitive mode was off, see svc.IndexAliases. It scans every short
+// path, hence it is run once by the index-aliases command rather than on New.
+func (a *App) IndexAliases(ctx context.Context) (int, error) {
+	targetURLStore, err := store.NewRedisKVStore(a.redis, "target")
+	if err != nil {
+		return 0, fmt.Errorf("could not create targetURLStore: %w", err)
+	}
+	aliasStore, err := store.NewRedisKVStore(a.redis, "alias")
+	if err != nil {
+		return 0, fmt.Errorf("could not create aliasStore: %w", err)
+	}
+	return svc.IndexAliases(ctx, targetURLStore, aliasStore)
+}
+
+// Start starts the background processing of metrics
+func (a *App) Start() {
+	a.metrics.Start()
+}
+
+// Close flushes the metrics and closes the redis client, the App can't be
+// used afterwards
+func (a *App) Close(ctx context.Context) error {
+	var errs []error
+	if err := a.metrics.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("could not stop metrics: %w", err))
+	}
+	if err := a.redis.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("could not close redis: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Handler routes the REST API
+func (a *App) Handler() http.Handler {
+	r := mux.NewRouter()
+	r.Use(rest.RequestIDMiddleware)
+	r.Use(rest.NewMetricsMiddleware(a.metrics))
+	metricsHandler := rest.NewMetricsHandler(a.log, a.metrics)
+	s := rest.NewShortURLHandler(a.log, a.urlShortner, a.metrics)
+	r.HandleFunc("/metrics", metricsHandler.Get).Methods("GET")
+	r.HandleFunc("/metrics/top-domains", metricsHandler.TopDomains).Methods("GET")
+	if a.config.ListLinksEnabled {
+		r.HandleFunc("/api/links", s.List).Methods("GET")
+	}
+	r.HandleFunc("/api/links:batch", s.Batch).Methods("POST")
+	r.HandleFunc("/", s.Create).Methods("POST")
+	r.HandleFunc("/{id}", s.Get).Methods("GET")
+	r.HandleFunc("/{id}", s.Put).Methods("PUT")
+	r.HandleFunc("/{id}", s.Delete).Methods("DELETE")
+	r.HandleFunc("/{id}/stats", s.Stats).Methods("GET")
+	return r
+}
+
+// Serve starts the App and serves the REST API until ctx is done. Then it
+// stops accepting connections, waits for the in-flight requests and closes
+// the App, all within ShutdownTimeout.
+func (a *App) Serve(ctx context.Context) error {
+	ln, err := net.Listen("tcp", a.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("could not listen: %w", err)
+	}
+	a.log.Infof("Starting listening on %s", ln.Addr())
+	return a.serve(ctx, ln, a.Handler())
+}
+
+func (a *App) serve(ctx context.Context, ln net.Listener, handler http.Handler) error {
+	a.Start()
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: a.config.ReadHeaderTimeout,
+		ReadTimeout:       a.config.ReadTimeout,
+		WriteTimeout:      a.config.WriteTimeout,
+		IdleTimeout:       a.config.IdleTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(ln)
+	}()
+	var errs []error
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		errs = append(errs, fmt.Errorf("could not serve: %w", err))
+	}
+
+	a.log.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("could not drain requests: %w", err))
+		// Give up on the requests still in flight
+		server.Close()
+	}
+	if err := a.Close(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func connectRedis(config Config) (*redis.Client, error) {
+	redis, err := store.NewRedisClient(config.RedisAddr, config.RedisPassword, config.RedisDB)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to redis: %w", err)
+	}
+	if err := store.CheckRedisConnection(redis); err != nil {
+		return nil, fmt.Errorf("could not connect to redis: %w", err)
+	}
+	return redis, nil
+}
+
+func (a *App) newMetrics() (metrics.Metrics, error) {
+	clickStore, err := store.NewRedisCounterStore(a.redis, "clicks")
+	if err != nil {
+		return nil, fmt.Errorf("could not create clickStore: %w", err)
+	}
+	domainShortens, err := a.newDomainShortensCollector()
+	if err != nil {
+		return nil, err
+	}
+	return metrics.NewMetrics(clickStore, metrics.WithCollector(metrics.DomainShortens, domainShortens)), nil
+}
+
+func (a *App) newDomainShortensCollector() (metrics.Collector, error) {
+	policy, err := metrics.ParseOverflowPolicy(a.config.CollectorOverflow)
+	if err != nil {
+		return nil, err
+	}
+	bufferSize := a.config.CollectorBufferSize
+	switch a.config.TopDomainsStore {
+	case "redis":
+		// Aggregated across instances, windows are not supported
+		rankStore, err := store.NewRedisRankStore(a.redis, "collectors")
+		if err != nil {
+			return nil, fmt.Errorf("could not create rankStore: %w", err)
+		}
+		return metrics.NewRankCollector(rankStore, metrics.DomainShortens, bufferSize, policy), nil
+	case "memory":
+		return metrics.NewWindowedCollector(a.config.TopDomainsCapacity, metrics.TopDomainsBucketWidth, metrics.TopDomainsRetention, bufferSize, policy), nil
+	default:
+		return nil, fmt.Errorf("unknown top_domains_store %q", a.config.TopDomainsStore)
+	}
+}
+
+func (a *App) newURLShortner() (svc.URLShortner, error) {
+	targetURLStore, err := store.NewRedisKVStore(a.redis, "target")
+	if err != nil {
+		return nil, fmt.Errorf("could not create targetURLStore: %w", err)
+	}
+	shortPathStore, err := store.NewRedisKVStore(a.redis, "short")
+	if err != nil {
+		return nil, fmt.Errorf("could not create shortPathStore: %w", err)
+	}
+	storeLatency := a.metrics.GetHistogram(metrics.StoreOperationDuration)
+	builder := svc.NewURLShortnerBuilder().
+		SetTargetURLStore(metrics.NewInstrumentedKVStore(targetURLStore, "target", storeLatency)).
+		SetCharset("abcdefghijklmnopqrstuvwxyz0123456789").
+		SetShortPathStore(metrics.NewInstrumentedKVStore(shortPathStore, "short", storeLatency)).
+		SetMetrics(a.metrics).
+		SetRetryBudget(a.config.ShortPathRetryBudget).
+		SetSecureRandom(a.config.SecureRandom).
+		SetCaseInsensitive(a.config.CaseInsensitive)
+	if a.config.CaseInsensitive {
+		aliasStore, err := store.NewRedisKVStore(a.redis, "alias")
+		if err != nil {
+			return nil, fmt.Errorf("could not create aliasStore: %w", err)
+		}
+		builder.SetAliasStore(metrics.NewInstrumentedKVStore(aliasStore, "alias", storeLatency))
+	}
+	switch a.config.IDStrategy {
+	case "random":
+	case "counter":
+		sequence, err := store.NewRedisSequence(a.redis, "seq", "short_path")
+		if err != nil {
+			return nil, fmt.Errorf("could not create sequence: %w", err)
+		}
+		builder.SetCounterIDs(sequence, a.config.IDObfuscate)
+	default:
+		return nil, fmt.Errorf("unknown id_strategy %q", a.config.IDStrategy)
+	}
+	if path := a.config.ReservedWordsFile; path != "" {
+		words, err := svc.LoadWordList(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not load reserved words: %w", err)
+		}
+		builder.SetReservedWords(words)
+	}
+	if path := a.config.BlockedWordsFile; path != "" {
+		words, err := svc.LoadWordList(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not load blocked words: %w", err)
+		}
+		builder.SetBlockedWords(words)
+	}
+	if style := a.config.ShortPathStyle; style != "" {
+		builder.SetDefaultStyle(style)
+	}
+	return builder.Build()
+}
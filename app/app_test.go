@@ -0,0 +1,285 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/thenilesh/url-shortner/svc"
+)
+
+func newTestConfig(t *testing.T) Config {
+	mr := miniredis.RunT(t)
+	config := DefaultConfig()
+	config.RedisAddr = mr.Addr()
+	return config
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("US_REDIS_ADDR", "redis:6379")
+	t.Setenv("US_REDIS_PASSWORD", "secret")
+	t.Setenv("US_CASE_INSENSITIVE", "true")
+	t.Setenv("US_LIST_LINKS_ENABLED", "true")
+
+	config, err := LoadConfig()
+	assert.NoError(t, err)
+	expected := DefaultConfig()
+	expected.RedisAddr = "redis:6379"
+	expected.RedisPassword = "secret"
+	expected.CaseInsensitive = true
+	expected.ListLinksEnabled = true
+	assert.Equal(t, expected, config)
+}
+
+func TestNew(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+
+	config := newTestConfig(t)
+	config.IDStrategy = "sequential"
+	_, err := New(config, log)
+	assert.EqualError(t, err, `unknown id_strategy "sequential"`)
+
+	config = DefaultConfig()
+	config.RedisAddr = "127.0.0.1:1"
+	_, err = New(config, log)
+	assert.Error(t, err)
+}
+
+func TestApp_Handler(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	a, err := New(newTestConfig(t), log)
+	assert.NoError(t, err)
+	a.Start()
+	handler := a.Handler()
+
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"short_path": "docs", "target_url": "https://example.com/docs"}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	req, _ = http.NewRequest(http.MethodGet, "/docs", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://example.com/docs", rr.Header().Get("Location"))
+
+	assert.NoError(t, a.Close(context.Background()))
+}
+
+func TestApp_Handler_listLinks(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	config := newTestConfig(t)
+	a, err := New(config, log)
+	assert.NoError(t, err)
+
+	// Listing is off by default
+	req, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
+	rr := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.NoError(t, a.Close(context.Background()))
+
+	config.ListLinksEnabled = true
+	a, err = New(config, log)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	a.Handler().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, a.Close(context.Background()))
+}
+
+func TestApp_deleteResetsClicks(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	ctx := context.Background()
+	a, err := New(newTestConfig(t), log)
+	assert.NoError(t, err)
+	a.Start()
+	handler := a.Handler()
+	_, err = a.URLShortner().CreateShortPath(ctx, "promo", "https://old.com", svc.CreateOptions{})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/promo", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Eventually(t, func() bool {
+		shortURL, err := a.URLShortner().GetShortURL(ctx, "promo")
+		return err == nil && shortURL.Clicks == 5
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, a.URLShortner().DeleteShortPath(ctx, "promo"))
+	_, err = a.URLShortner().CreateShortPath(ctx, "promo", "https://new.com", svc.CreateOptions{})
+	assert.NoError(t, err)
+	shortURL, err := a.URLShortner().GetShortURL(ctx, "promo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), shortURL.Clicks)
+	assert.NoError(t, a.Close(ctx))
+}
+
+func TestApp_expiryResetsClicks(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	config := DefaultConfig()
+	config.RedisAddr = mr.Addr()
+	a, err := New(config, log)
+	assert.NoError(t, err)
+	a.Start()
+	handler := a.Handler()
+	expiresAt := time.Now().Add(time.Hour)
+	_, err = a.URLShortner().CreateShortPath(ctx, "promo", "https://old.com", svc.CreateOptions{ExpiresAt: expiresAt})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/promo", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Eventually(t, func() bool {
+		shortURL, err := a.URLShortner().GetShortURL(ctx, "promo")
+		return err == nil && shortURL.Clicks == 5
+	}, time.Second, 10*time.Millisecond)
+
+	mr.FastForward(time.Hour)
+	_, err = a.URLShortner().GetTargetURL(ctx, "promo")
+	assert.IsType(t, &svc.ErrNotFound{}, err)
+	_, err = a.URLShortner().CreateShortPath(ctx, "promo", "https://new.com", svc.CreateOptions{})
+	assert.NoError(t, err)
+	shortURL, err := a.URLShortner().GetShortURL(ctx, "promo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), shortURL.Clicks)
+	assert.NoError(t, a.Close(ctx))
+}
+
+func TestNew_caseInsensitive(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	ctx := context.Background()
+	config := newTestConfig(t)
+	a, err := New(config, log)
+	assert.NoError(t, err)
+	_, err = a.URLShortner().CreateShortPath(ctx, "AbC", "https://a.com", svc.CreateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, a.Close(ctx))
+
+	// Mixed-case shortPaths created while the mode was off are indexed
+	// only on demand
+	config.CaseInsensitive = true
+	a, err = New(config, log)
+	assert.NoError(t, err)
+	_, err = a.URLShortner().GetTargetURL(ctx, "ABC")
+	assert.IsType(t, &svc.ErrNotFound{}, err)
+	added, err := a.IndexAliases(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, added)
+	targetURL, err := a.URLShortner().GetTargetURL(ctx, "ABC")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://a.com", targetURL)
+	_, err = a.URLShortner().CreateShortPath(ctx, "ABC", "https://b.com", svc.CreateOptions{})
+	assert.IsType(t, &svc.ErrConflict{}, err)
+	assert.NoError(t, a.Close(ctx))
+}
+
+// blockingHandler blocks requests to /slow until release is closed, after
+// closing entered
+func blockingHandler(next http.Handler, entered chan struct{}, release chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestApp_serve_drainsInFlightRequests(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	a, err := New(newTestConfig(t), log)
+	assert.NoError(t, err)
+	_, err = a.URLShortner().CreateShortPath(context.Background(), "slow", "https://example.com/slow", svc.CreateOptions{})
+	assert.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- a.serve(ctx, ln, blockingHandler(a.Handler(), entered, release))
+	}()
+	responses := make(chan *http.Response, 1)
+	go func() {
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		resp, err := client.Get("http://" + addr + "/slow")
+		assert.NoError(t, err)
+		responses <- resp
+	}()
+	<-entered
+	cancel()
+
+	// No new connections once shutdown begins, but the request in flight
+	// keeps the server up
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}, time.Second, 10*time.Millisecond)
+	select {
+	case err := <-served:
+		t.Fatalf("serve returned with a request in flight: %v", err)
+	default:
+	}
+
+	close(release)
+	resp := <-responses
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "https://example.com/slow", resp.Header.Get("Location"))
+	assert.NoError(t, <-served)
+	assert.ErrorIs(t, a.redis.Ping(context.Background()).Err(), redis.ErrClosed)
+}
+
+func TestApp_serve_shutdownTimeout(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+	config := newTestConfig(t)
+	config.ShutdownTimeout = 50 * time.Millisecond
+	a, err := New(config, log)
+	assert.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- a.serve(ctx, ln, blockingHandler(a.Handler(), entered, release))
+	}()
+	go http.Get("http://" + ln.Addr().String() + "/slow")
+	<-entered
+	cancel()
+
+	select {
+	case err := <-served:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not give up on the request in flight")
+	}
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/thenilesh/url-shortner/metrics"
+)
+
+const appName = "url-shortner"
+
+// Config holds the settings of the app, read from config.properties in
+// /etc/url-shortner or the working directory, overridden by US_ prefixed
+// environment variables
+type Config struct {
+	LogLevel   string
+	ListenAddr string
+	// Timeouts of the HTTP server, zero means no timeout
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// Time given to in-flight requests and metrics to finish on shutdown
+	ShutdownTimeout time.Duration
+	RedisAddr       string
+	RedisPassword   string
+	RedisDB         int
+	// memory keeps top domains per instance, redis shares them across
+	// instances
+	TopDomainsStore string
+	// Number of domains tracked for top domains in memory, 0 tracks every
+	// domain
+	TopDomainsCapacity int
+	// Keys buffered by collectors, beyond that CollectorOverflow decides
+	// whether to drop or block
+	CollectorBufferSize int
+	CollectorOverflow   string
+	// random generates short paths randomly, counter from a redis counter
+	IDStrategy  string
+	IDObfuscate bool
+	// Style of short paths generated when request has no style: random,
+	// words or counter. Empty picks the style of IDStrategy.
+	ShortPathStyle string
+	// Files with one word per line. Reserved words add to the routes,
+	// blocked words replace the built-in list.
+	ReservedWordsFile string
+	BlockedWordsFile  string
+	// Generate random short paths with crypto/rand, so they can't be guessed
+	SecureRandom bool
+	// Resolve short paths regardless of case, new ones are stored lower case.
+	// Mixed-case ones stored earlier are indexed by their lower case with the
+	// index-aliases command, run once all the instances have the mode on.
+	CaseInsensitive bool
+	// Generated short paths tried before a create fails
+	ShortPathRetryBudget int
+	// Serve GET /api/links, which lists every link to anyone who can reach
+	// the server. Enable it only where the server is not public.
+	ListLinksEnabled bool
+}
+
+// DefaultConfig returns the Config used when nothing is configured
+func DefaultConfig() Config {
+	return Config{
+		LogLevel:             "info",
+		ListenAddr:           ":8080",
+		ReadHeaderTimeout:    5 * time.Second,
+		ReadTimeout:          10 * time.Second,
+		WriteTimeout:         30 * time.Second,
+		IdleTimeout:          2 * time.Minute,
+		ShutdownTimeout:      15 * time.Second,
+		RedisAddr:            "localhost:6379",
+		TopDomainsStore:      "memory",
+		CollectorBufferSize:  metrics.DefaultCollectorBufferSize,
+		CollectorOverflow:    "drop",
+		IDStrategy:           "random",
+		IDObfuscate:          true,
+		SecureRandom:         true,
+		ShortPathRetryBudget: 3,
+	}
+}
+
+// LoadConfig reads the Config, the config file is optional
+func LoadConfig() (Config, error) {
+	defaults := DefaultConfig()
+	v := viper.New()
+	v.SetDefault("log_level", defaults.LogLevel)
+	v.SetDefault("listen_addr", defaults.ListenAddr)
+	v.SetDefault("http_read_header_timeout", defaults.ReadHeaderTimeout)
+	v.SetDefault("http_read_timeout", defaults.ReadTimeout)
+	v.SetDefault("http_write_timeout", defaults.WriteTimeout)
+	v.SetDefault("http_idle_timeout", defaults.IdleTimeout)
+	v.SetDefault("shutdown_timeout", defaults.ShutdownTimeout)
+	v.SetDefault("redis_addr", defaults.RedisAddr)
+	v.SetDefault("redis_password", defaults.RedisPassword)
+	v.SetDefault("redis_db", defaults.RedisDB)
+	v.SetDefault("top_domains_store", defaults.TopDomainsStore)
+	v.SetDefault("top_domains_capacity", defaults.TopDomainsCapacity)
+	v.SetDefault("collector_buffer_size", defaults.CollectorBufferSize)
+	v.SetDefault("collector_overflow", defaults.CollectorOverflow)
+	v.SetDefault("id_strategy", defaults.IDStrategy)
+	v.SetDefault("id_obfuscate", defaults.IDObfuscate)
+	v.SetDefault("short_path_style", defaults.ShortPathStyle)
+	v.SetDefault("reserved_words_file", defaults.ReservedWordsFile)
+	v.SetDefault("blocked_words_file", defaults.BlockedWordsFile)
+	v.SetDefault("secure_random", defaults.SecureRandom)
+	v.SetDefault("case_insensitive", defaults.CaseInsensitive)
+	v.SetDefault("short_path_retry_budget", defaults.ShortPathRetryBudget)
+	v.SetDefault("list_links_enabled", defaults.ListLinksEnabled)
+
+	v.SetConfigName("config")
+	v.AddConfigPath(fmt.Sprintf("/etc/%s", appName))
+	v.AddConfigPath(".")
+	v.SetEnvPrefix("us")
+	v.AutomaticEnv()
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return Config{}, fmt.Errorf("could not read config file: %w", err)
+		}
+	}
+
+	return Config{
+		LogLevel:             v.GetString("log_level"),
+		ListenAddr:           v.GetString("listen_addr"),
+		ReadHeaderTimeout:    v.GetDuration("http_read_header_timeout"),
+		ReadTimeout:          v.GetDuration("http_read_timeout"),
+		WriteTimeout:         v.GetDuration("http_write_timeout"),
+		IdleTimeout:          v.GetDuration("http_idle_timeout"),
+		ShutdownTimeout:      v.GetDuration("shutdown_timeout"),
+		RedisAddr:            v.GetString("redis_addr"),
+		RedisPassword:        v.GetString("redis_password"),
+		RedisDB:              v.GetInt("redis_db"),
+		TopDomainsStore:      v.GetString("top_domains_store"),
+		TopDomainsCapacity:   v.GetInt("top_domains_capacity"),
+		CollectorBufferSize:  v.GetInt("collector_buffer_size"),
+		CollectorOverflow:    v.GetString("collector_overflow"),
+		IDStrategy:           v.GetString("id_strategy"),
+		IDObfuscate:          v.GetBool("id_obfuscate"),
+		ShortPathStyle:       v.GetString("short_path_style"),
+		ReservedWordsFile:    v.GetString("reserved_words_file"),
+		BlockedWordsFile:     v.GetString("blocked_words_file"),
+		SecureRandom:         v.GetBool("secure_random"),
+		CaseInsensitive:      v.GetBool("case_insensitive"),
+		ShortPathRetryBudget: v.GetInt("short_path_retry_budget"),
+		ListLinksEnabled:     v.GetBool("list_links_enabled"),
+	}, nil
+}
@@ -0,0 +1,131 @@
+// Package cli runs the subcommands of the url-shortner binary
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thenilesh/url-shortner/app"
+)
+
+// closeTimeout bounds flushing of metrics when a command is done
+const closeTimeout = 10 * time.Second
+
+// env is what the commands run with
+type env struct {
+	config app.Config
+	log    *logrus.Logger
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// command runs with the arguments following its name and returns the exit
+// code
+type command struct {
+	usage string
+	run   func(e *env, args []string) int
+}
+
+var commands = map[string]command{
+	"serve":         {usage: "serve the REST API", run: runServe},
+	"create":        {usage: "shorten a target URL", run: runCreate},
+	"resolve":       {usage: "print the target URL of a short path", run: runResolve},
+	"delete":        {usage: "delete a short path", run: runDelete},
+	"stats":         {usage: "print click stats of a short path", run: runStats},
+	"import":        {usage: "import short paths from CSV or JSON Lines", run: runImport},
+	"export":        {usage: "export short paths to CSV or JSON Lines", run: runExport},
+	"index-aliases": {usage: "index mixed-case short paths for case-insensitive mode", run: runIndexAliases},
+}
+
+// Run runs the command named by the first of args, serve if args are
+// empty, and returns the exit code. Logs are written to stderr.
+func Run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	log := logrus.New()
+	log.SetOutput(stderr)
+	config, err := app.LoadConfig()
+	if err != nil {
+		log.WithError(err).Error("Failed to load config")
+		return 1
+	}
+	logLevel, err := logrus.ParseLevel(config.LogLevel)
+	if err != nil {
+		log.WithError(err).Error("Failed to parse log level")
+		return 1
+	}
+	log.Level = logLevel
+	return run(&env{config: config, log: log, stdin: stdin, stdout: stdout}, args)
+}
+
+func run(e *env, args []string) int {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		e.log.Errorf("Unknown command %q", name)
+		fmt.Fprintln(e.log.Out, usage())
+		return 2
+	}
+	return cmd.run(e, args)
+}
+
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString("Commands:\n")
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("  %-13s %s\n", name, commands[name].usage))
+	}
+	sb.WriteString("Run a command with -h for its flags")
+	return sb.String()
+}
+
+func runServe(e *env, args []string) int {
+	if len(args) > 0 {
+		e.log.Errorf("serve takes no arguments")
+		return 2
+	}
+	a, err := app.New(e.config, e.log)
+	if err != nil {
+		e.log.WithError(err).Error("Failed to create app")
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	e.log.Info("Starting server")
+	if err := a.Serve(ctx); err != nil {
+		e.log.WithError(err).Error("Failed to serve")
+		return 1
+	}
+	return 0
+}
+
+// startApp creates and starts the App for commands working on the store
+// directly, the returned func closes it
+func startApp(e *env) (*app.App, func(), error) {
+	a, err := app.New(e.config, e.log)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.Start()
+	return a, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := a.Close(ctx); err != nil {
+			e.log.WithError(err).Error("Failed to close app")
+		}
+	}, nil
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/thenilesh/url-shortner/app"
+)
+
+func newTestEnv(t *testing.T) (*env, *bytes.Buffer) {
+	mr := miniredis.RunT(t)
+	config := app.DefaultConfig()
+	config.RedisAddr = mr.Addr()
+	log := logrus.New()
+	log.SetOutput(&bytes.Buffer{})
+	stdout := &bytes.Buffer{}
+	return &env{config: config, log: log, stdin: strings.NewReader(""), stdout: stdout}, stdout
+}
+
+// runCommand runs args, returning the exit code and stdout
+func runCommand(e *env, stdout *bytes.Buffer, args ...string) (int, string) {
+	stdout.Reset()
+	code := run(e, args)
+	return code, stdout.String()
+}
+
+func TestRun_links(t *testing.T) {
+	e, stdout := newTestEnv(t)
+	a, err := app.New(e.config, e.log)
+	assert.NoError(t, err)
+	a.Start()
+	server := httptest.NewServer(a.Handler())
+	defer server.Close()
+
+	// Either directly on the store or through the server
+	for _, via := range [][]string{{}, {"-server", server.URL}} {
+		args := func(command string, rest ...string) []string {
+			return append(append([]string{command}, via...), rest...)
+		}
+
+		code, out := runCommand(e, stdout, args("create", "-short-path", "docs", "https://example.com/docs")...)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "docs\n", out)
+
+		code, out = runCommand(e, stdout, args("create", "https://example.com/blog")...)
+		assert.Equal(t, 0, code)
+		assert.NotEmpty(t, strings.TrimSpace(out))
+
+		code, _ = runCommand(e, stdout, args("create", "-short-path", "docs", "https://example.com/other")...)
+		assert.Equal(t, 1, code)
+
+		code, out = runCommand(e, stdout, args("resolve", "docs")...)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "https://example.com/docs\n", out)
+
+		code, out = runCommand(e, stdout, args("stats", "docs")...)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "total: 0\n", out)
+
+		code, _ = runCommand(e, stdout, args("delete", "docs")...)
+		assert.Equal(t, 0, code)
+		code, _ = runCommand(e, stdout, args("resolve", "docs")...)
+		assert.Equal(t, 1, code)
+		code, _ = runCommand(e, stdout, args("stats", "docs")...)
+		assert.Equal(t, 1, code)
+	}
+}
+
+func TestRun_transfer(t *testing.T) {
+	e, stdout := newTestEnv(t)
+	e.stdin = strings.NewReader("docs,https://example.com/docs\nblog,https://example.com/blog\n")
+
+	code, _ := runCommand(e, stdout, "import")
+	assert.Equal(t, 0, code)
+
+	code, out := runCommand(e, stdout, "export", "-format", "jsonl")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"short_path":"blog","target_url":"https://example.com/blog"}`+"\n"+
+		`{"short_path":"docs","target_url":"https://example.com/docs"}`+"\n", out)
+}
+
+func TestRun_indexAliases(t *testing.T) {
+	e, stdout := newTestEnv(t)
+	code, _ := runCommand(e, stdout, "create", "-short-path", "Docs", "https://example.com/docs")
+	assert.Equal(t, 0, code)
+
+	// Indexing is pointless unless the mode is on
+	code, _ = runCommand(e, stdout, "index-aliases")
+	assert.Equal(t, 2, code)
+
+	e.config.CaseInsensitive = true
+	code, _ = runCommand(e, stdout, "index-aliases")
+	assert.Equal(t, 0, code)
+	code, out := runCommand(e, stdout, "resolve", "DOCS")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "https://example.com/docs\n", out)
+}
+
+func TestRun_usage(t *testing.T) {
+	e, stdout := newTestEnv(t)
+
+	code, _ := runCommand(e, stdout, "shorten")
+	assert.Equal(t, 2, code)
+	code, _ = runCommand(e, stdout, "resolve")
+	assert.Equal(t, 2, code)
+	code, _ = runCommand(e, stdout, "create", "-ttl", "-1h", "https://example.com")
+	assert.Equal(t, 2, code)
+	code, _ = runCommand(e, stdout, "serve", "extra")
+	assert.Equal(t, 2, code)
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/thenilesh/url-shortner/metrics"
+	"github.com/thenilesh/url-shortner/rest"
+	"github.com/thenilesh/url-shortner/svc"
+)
+
+// linkClient manages short paths either on the store or through a server
+type linkClient interface {
+	Create(ctx context.Context, shortPath string, targetURL string, opts svc.CreateOptions) (string, error)
+	Resolve(ctx context.Context, shortPath string) (string, error)
+	Delete(ctx context.Context, shortPath string) error
+	Stats(ctx context.Context, shortPath string) (metrics.ClickStats, error)
+}
+
+// localClient works on the store directly
+type localClient struct {
+	urlShortner svc.URLShortner
+	metrics     metrics.Metrics
+}
+
+func (c *localClient) Create(ctx context.Context, shortPath string, targetURL string, opts svc.CreateOptions) (string, error) {
+	return c.urlShortner.CreateShortPath(ctx, shortPath, targetURL, opts)
+}
+
+func (c *localClient) Resolve(ctx context.Context, shortPath string) (string, error) {
+	return c.urlShortner.GetTargetURL(ctx, shortPath)
+}
+
+func (c *localClient) Delete(ctx context.Context, shortPath string) error {
+	return c.urlShortner.DeleteShortPath(ctx, shortPath)
+}
+
+func (c *localClient) Stats(ctx context.Context, shortPath string) (metrics.ClickStats, error) {
+	key, _, err := c.urlShortner.ResolveShortPath(ctx, shortPath)
+	if err != nil {
+		return metrics.ClickStats{}, err
+	}
+	return c.metrics.GetClickTracker().GetClickStats(ctx, key)
+}
+
+// httpClient talks to the REST API of a running server
+type httpClient struct {
+	server string
+	client *http.Client
+}
+
+func newHTTPClient(server string) *httpClient {
+	return &httpClient{
+		server: strings.TrimSuffix(server, "/"),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			// Resolving must not follow the redirect to the target URL
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (c *httpClient) Create(ctx context.Context, shortPath string, targetURL string, opts svc.CreateOptions) (string, error) {
+	shortURL := rest.ShortURL{
+		ShortPath: shortPath,
+		TargetURL: targetURL,
+		Tags:      opts.Tags,
+		Style:     opts.Style,
+	}
+	if !opts.ExpiresAt.IsZero() {
+		shortURL.ExpiresAt = &opts.ExpiresAt
+	}
+	body, err := json.Marshal(shortURL)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/", body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", responseError(resp)
+	}
+	return strings.TrimPrefix(resp.Header.Get("Location"), "/"), nil
+}
+
+func (c *httpClient) Resolve(ctx context.Context, shortPath string) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/"+url.PathEscape(shortPath)+"?redirect=false", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp)
+	}
+	var shortURL rest.ShortURL
+	if err := json.NewDecoder(resp.Body).Decode(&shortURL); err != nil {
+		return "", fmt.Errorf("could not decode response: %w", err)
+	}
+	return shortURL.TargetURL, nil
+}
+
+func (c *httpClient) Delete(ctx context.Context, shortPath string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/"+url.PathEscape(shortPath), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (c *httpClient) Stats(ctx context.Context, shortPath string) (metrics.ClickStats, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/"+url.PathEscape(shortPath)+"/stats", nil)
+	if err != nil {
+		return metrics.ClickStats{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return metrics.ClickStats{}, responseError(resp)
+	}
+	var stats rest.ClickStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return metrics.ClickStats{}, fmt.Errorf("could not decode response: %w", err)
+	}
+	return metrics.ClickStats{Total: stats.TotalClicks, Daily: stats.Daily}, nil
+}
+
+func (c *httpClient) do(ctx context.Context, method string, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.client.Do(req)
+}
+
+// responseError converts the error response to error, with the message of
+// the server if any
+func responseError(resp *http.Response) error {
+	var response rest.Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err == nil && response.Message != "" {
+		return fmt.Errorf("%s: %s", resp.Status, response.Message)
+	}
+	return fmt.Errorf("%s", resp.Status)
+}
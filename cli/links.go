@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thenilesh/url-shortner/svc"
+)
+
+// linkFlags parses flags of the commands managing short paths, along with
+// the -server flag common to them
+type linkFlags struct {
+	*flag.FlagSet
+	server *string
+}
+
+func newLinkFlags(e *env, name string, args string) *linkFlags {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.log.Out)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return &linkFlags{
+		FlagSet: flags,
+		server:  flags.String("server", "", "URL of a running server to go through, the store is used directly if not set"),
+	}
+}
+
+// parse parses args, which must have n positional arguments
+func (f *linkFlags) parse(args []string, n int) bool {
+	if err := f.Parse(args); err != nil {
+		return false
+	}
+	if f.NArg() != n {
+		f.Usage()
+		return false
+	}
+	return true
+}
+
+// withClient runs fn with linkClient of -server if set, else of the store
+func (f *linkFlags) withClient(e *env, fn func(ctx context.Context, client linkClient) error) int {
+	ctx := context.Background()
+	var client linkClient
+	if *f.server != "" {
+		client = newHTTPClient(*f.server)
+	} else {
+		a, closeApp, err := startApp(e)
+		if err != nil {
+			e.log.WithError(err).Error("Failed to create app")
+			return 1
+		}
+		defer closeApp()
+		client = &localClient{urlShortner: a.URLShortner(), metrics: a.Metrics()}
+	}
+	if err := fn(ctx, client); err != nil {
+		e.log.Error(err)
+		return 1
+	}
+	return 0
+}
+
+func runCreate(e *env, args []string) int {
+	flags := newLinkFlags(e, "create", "target_url")
+	shortPath := flags.String("short-path", "", "short path to create, generated if not set")
+	ttl := flags.Duration("ttl", 0, "time after which the short path expires, never if not set")
+	style := flags.String("style", "", "style of the generated short path: random, words or counter")
+	tags := flags.String("tags", "", "comma separated tags")
+	if !flags.parse(args, 1) {
+		return 2
+	}
+	opts := svc.CreateOptions{Style: *style}
+	if *ttl < 0 {
+		e.log.Error("ttl must be positive")
+		return 2
+	}
+	if *ttl > 0 {
+		opts.ExpiresAt = time.Now().Add(*ttl)
+	}
+	if *tags != "" {
+		opts.Tags = strings.Split(*tags, ",")
+	}
+	return flags.withClient(e, func(ctx context.Context, client linkClient) error {
+		created, err := client.Create(ctx, *shortPath, flags.Arg(0), opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, created)
+		return nil
+	})
+}
+
+func runResolve(e *env, args []string) int {
+	flags := newLinkFlags(e, "resolve", "short_path")
+	if !flags.parse(args, 1) {
+		return 2
+	}
+	return flags.withClient(e, func(ctx context.Context, client linkClient) error {
+		targetURL, err := client.Resolve(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, targetURL)
+		return nil
+	})
+}
+
+func runDelete(e *env, args []string) int {
+	flags := newLinkFlags(e, "delete", "short_path")
+	if !flags.parse(args, 1) {
+		return 2
+	}
+	return flags.withClient(e, func(ctx context.Context, client linkClient) error {
+		return client.Delete(ctx, flags.Arg(0))
+	})
+}
+
+func runStats(e *env, args []string) int {
+	flags := newLinkFlags(e, "stats", "short_path")
+	if !flags.parse(args, 1) {
+		return 2
+	}
+	return flags.withClient(e, func(ctx context.Context, client linkClient) error {
+		stats, err := client.Stats(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "total: %d\n", stats.Total)
+		days := make([]string, 0, len(stats.Daily))
+		for day := range stats.Daily {
+			days = append(days, day)
+		}
+		sort.Strings(days)
+		for _, day := range days {
+			fmt.Fprintf(e.stdout, "%s: %d\n", day, stats.Daily[day])
+		}
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"flag"
+)
+
+// runIndexAliases indexes the mixed-case short paths for case-insensitive
+// mode, it returns the exit code. Running it again only indexes the short
+// paths added since.
+func runIndexAliases(e *env, args []string) int {
+	log := e.log
+	flags := flag.NewFlagSet("index-aliases", flag.ContinueOnError)
+	flags.SetOutput(log.Out)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		log.Errorf("index-aliases takes no arguments")
+		return 2
+	}
+	if !e.config.CaseInsensitive {
+		log.Errorf("index-aliases needs case_insensitive to be set")
+		return 2
+	}
+
+	a, closeApp, err := startApp(e)
+	if err != nil {
+		log.WithError(err).Error("Failed to create app")
+		return 1
+	}
+	defer closeApp()
+	added, err := a.IndexAliases(context.Background())
+	if err != nil {
+		log.WithError(err).Error("Failed to index aliases")
+		return 1
+	}
+	log.Infof("Indexed %d mixed-case short paths", added)
+	return 0
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thenilesh/url-shortner/transfer"
+)
+
+// runImport imports short paths from a file or stdin, it returns the exit
+// code, which is 1 if any record was not imported
+func runImport(e *env, args []string) int {
+	log := e.log
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(log.Out)
+	file := flags.String("file", "-", "file to import from, - for stdin")
+	format := flags.String("format", "", "csv or jsonl, guessed from file extension if not set")
+	dryRun := flags.Bool("dry-run", false, "validate and report conflicts without importing")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	in := e.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.WithError(err).Error("Failed to open file")
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	reader, err := transfer.NewRecordReader(in, transferFormat(*format, *file))
+	if err != nil {
+		log.WithError(err).Error("Failed to read records")
+		return 1
+	}
+
+	a, closeApp, err := startApp(e)
+	if err != nil {
+		log.WithError(err).Error("Failed to create app")
+		return 1
+	}
+	// Closing flushes the collectors, so that shared ones count the imports
+	defer closeApp()
+	summary, err := transfer.Import(context.Background(), a.URLShortner(), reader, *dryRun, func(result transfer.ImportResult) {
+		entry := log.WithFields(logrus.Fields{
+			"line":       result.Line,
+			"short_path": result.Record.ShortPath,
+			"target_url": result.Record.TargetURL,
+		})
+		switch result.Status {
+		case transfer.StatusCreated, transfer.StatusExists:
+			entry.Debugf("%s as %s", result.Status, result.ShortPath)
+		default:
+			entry.WithError(result.Err).Warn(result.Status)
+		}
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to import")
+		return 1
+	}
+	log.WithField("dry_run", *dryRun).Infof("Imported %s", summary)
+	if summary[transfer.StatusConflict]+summary[transfer.StatusInvalid]+summary[transfer.StatusFailed] > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runExport exports short paths to a file or stdout, it returns the exit
+// code
+func runExport(e *env, args []string) int {
+	log := e.log
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(log.Out)
+	file := flags.String("file", "-", "file to export to, - for stdout")
+	format := flags.String("format", "", "csv or jsonl, guessed from file extension if not set")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	out := e.stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			log.WithError(err).Error("Failed to create file")
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	writer, err := transfer.NewRecordWriter(out, transferFormat(*format, *file))
+	if err != nil {
+		log.WithError(err).Error("Failed to write records")
+		return 1
+	}
+
+	a, closeApp, err := startApp(e)
+	if err != nil {
+		log.WithError(err).Error("Failed to create app")
+		return 1
+	}
+	defer closeApp()
+	exported, err := transfer.Export(context.Background(), a.URLShortner(), writer)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to export after %d short paths", exported)
+		return 1
+	}
+	log.Infof("Exported %d short paths", exported)
+	return 0
+}
+
+// transferFormat returns format if set, else the one of file extension,
+// defaulting to csv
+func transferFormat(format string, file string) string {
+	if format != "" {
+		return format
+	}
+	if strings.EqualFold(filepath.Ext(file), fmt.Sprintf(".%s", transfer.FormatJSONL)) {
+		return transfer.FormatJSONL
+	}
+	return transfer.FormatCSV
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/thenilesh/url-shortner/store"
+)
+
+const (
+	totalClicksField = "total"
+	clickDayLayout   = "2006-01-02"
+)
+
+// ClickEvent is emitted every time a shortPath gets redirected
+type ClickEvent struct {
+	ShortPath string
+	Timestamp time.Time
+	Referrer  string
+	UserAgent string
+	// Hash of client IP, raw IP is not kept for privacy
+	RemoteIPHash string
+}
+
+// ClickStats are the clicks of a shortPath aggregated by ClickTracker
+type ClickStats struct {
+	Total int64
+	// Maps UTC day in YYYY-MM-DD format to clicks on that day
+	Daily map[string]int64
+}
+
+type ClickTracker interface {
+	Start()
+	// Stop aggregates the queued events and terminates the goroutine
+	// started by Start
+	Stop(ctx context.Context) error
+	// Track queues the event for aggregation. It never blocks, the event is
+	// dropped if the queue is full.
+	Track(event ClickEvent)
+	GetClickStats(ctx context.Context, shortPath string) (ClickStats, error)
+	// DeleteClickStats removes the clicks of shortPath, so that it starts
+	// afresh if created again. Events still queued are aggregated afterwards.
+	DeleteClickStats(ctx context.Context, shortPath string) error
+}
+
+type clickTracker struct {
+	events       chan ClickEvent
+	counterStore store.CounterStore
+	dropped      atomic.Int64
+	failed       atomic.Int64
+	worker       worker
+}
+
+func newClickTracker(counterStore store.CounterStore, queueSize int) *clickTracker {
+	return &clickTracker{
+		events:       make(chan ClickEvent, queueSize),
+		counterStore: counterStore,
+	}
+}
+
+func (c *clickTracker) Start() {
+	c.worker.start(func(stop <-chan struct{}) {
+		for {
+			select {
+			case event := <-c.events:
+				c.aggregate(event)
+			case <-stop:
+				c.drain()
+				return
+			}
+		}
+	})
+}
+
+func (c *clickTracker) drain() {
+	for {
+		select {
+		case event := <-c.events:
+			c.aggregate(event)
+		default:
+			return
+		}
+	}
+}
+
+func (c *clickTracker) Stop(ctx context.Context) error {
+	return c.worker.shutdown(ctx)
+}
+
+func (c *clickTracker) Track(event ClickEvent) {
+	select {
+	case c.events <- event:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+func (c *clickTracker) aggregate(event ClickEvent) {
+	ctx := context.Background()
+	day := event.Timestamp.UTC().Format(clickDayLayout)
+	if err := c.counterStore.Incr(ctx, event.ShortPath, totalClicksField, 1); err != nil {
+		c.failed.Add(1)
+		return
+	}
+	if err := c.counterStore.Incr(ctx, event.ShortPath, day, 1); err != nil {
+		c.failed.Add(1)
+	}
+}
+
+func (c *clickTracker) DeleteClickStats(ctx context.Context, shortPath string) error {
+	return c.counterStore.Delete(ctx, shortPath)
+}
+
+func (c *clickTracker) GetClickStats(ctx context.Context, shortPath string) (ClickStats, error) {
+	counters, err := c.counterStore.GetAll(ctx, shortPath)
+	if err != nil {
+		return ClickStats{}, err
+	}
+	stats := ClickStats{
+		Daily: make(map[string]int64),
+	}
+	for field, value := range counters {
+		if field == totalClicksField {
+			stats.Total = value
+		} else {
+			stats.Daily[field] = value
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thenilesh/url-shortner/store"
+)
+
+func TestClickTracker(t *testing.T) {
+	ctx := context.Background()
+	tracker := newClickTracker(store.NewGoMapCounterStore(), 10)
+
+	day1 := time.Date(2023, 10, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	tracker.Track(ClickEvent{ShortPath: "abc", Timestamp: day1})
+	tracker.Track(ClickEvent{ShortPath: "abc", Timestamp: day2})
+	tracker.Track(ClickEvent{ShortPath: "abc", Timestamp: day2})
+	tracker.Track(ClickEvent{ShortPath: "xyz", Timestamp: day2})
+	tracker.Start()
+
+	assert.Eventually(t, func() bool {
+		stats, err := tracker.GetClickStats(ctx, "abc")
+		return err == nil && stats.Total == 3
+	}, time.Second, 10*time.Millisecond)
+	stats, err := tracker.GetClickStats(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"2023-10-01": 1, "2023-10-02": 2}, stats.Daily)
+
+	stats, err = tracker.GetClickStats(ctx, "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, ClickStats{Daily: map[string]int64{}}, stats)
+
+	assert.NoError(t, tracker.DeleteClickStats(ctx, "abc"))
+	stats, err = tracker.GetClickStats(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, ClickStats{Daily: map[string]int64{}}, stats)
+}
+
+func TestClickTracker_Track_neverBlocks(t *testing.T) {
+	tracker := newClickTracker(store.NewGoMapCounterStore(), 2)
+
+	// Not started, hence nobody drains the queue
+	for i := 0; i < 5; i++ {
+		tracker.Track(ClickEvent{ShortPath: "abc", Timestamp: time.Now()})
+	}
+	assert.Equal(t, int64(3), tracker.dropped.Load())
+}
+
+func TestClickTracker_Stop(t *testing.T) {
+	ctx := context.Background()
+	tracker := newClickTracker(store.NewGoMapCounterStore(), 100)
+	for i := 0; i < 50; i++ {
+		tracker.Track(ClickEvent{ShortPath: "abc", Timestamp: time.Now()})
+	}
+	tracker.Start()
+
+	assert.NoError(t, tracker.Stop(ctx))
+	// Queued events are aggregated before Stop returns
+	stats, err := tracker.GetClickStats(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), stats.Total)
+}
+
+func TestClickTracker_Stop_timeout(t *testing.T) {
+	release := make(chan struct{})
+	tracker := newClickTracker(blockingCounterStore{release: release}, 10)
+	tracker.Track(ClickEvent{ShortPath: "abc", Timestamp: time.Now()})
+	tracker.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tracker.Stop(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, tracker.Stop(context.Background()))
+}
+
+// blockingCounterStore blocks Incr until release is closed
+type blockingCounterStore struct {
+	store.CounterStore
+	release chan struct{}
+}
+
+func (s blockingCounterStore) Incr(ctx context.Context, key string, field string, delta int64) error {
+	<-s.release
+	return nil
+}
@@ -16,44 +16,46 @@ type Heap interface {
 }
 
 func NewHeap() Heap {
-	h := &maxHeap{}
+	h := &maxHeap{
+		index: make(map[string]int),
+	}
 	heap.Init(h)
 	return h
 }
 
-type maxHeap []KeyValuePair
+// maxHeap keeps position of every key in index, so that a key can be
+// incremented in O(log n) without scanning the entries
+type maxHeap struct {
+	entries []KeyValuePair
+	// Maps key to its position in entries
+	index map[string]int
+}
 
-func (h maxHeap) Len() int           { return len(h) }
-func (h maxHeap) Less(i, j int) bool { return h[i].Value > h[j].Value }
-func (h maxHeap) Swap(i, j int) {
-	h[i], h[j] = h[j], h[i]
+func (h *maxHeap) Len() int           { return len(h.entries) }
+func (h *maxHeap) Less(i, j int) bool { return h.entries[i].Value > h.entries[j].Value }
+func (h *maxHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].Key] = i
+	h.index[h.entries[j].Key] = j
 }
 
 func (h *maxHeap) Push(x interface{}) {
 	kv := x.(KeyValuePair)
-	*h = append(*h, kv)
+	h.index[kv.Key] = len(h.entries)
+	h.entries = append(h.entries, kv)
 }
 
 func (h *maxHeap) Pop() interface{} {
-	old := *h
-	n := len(old)
-	kv := old[n-1]
-	*h = old[0 : n-1]
+	n := len(h.entries)
+	kv := h.entries[n-1]
+	h.entries = h.entries[0 : n-1]
+	delete(h.index, kv.Key)
 	return kv
 }
 
-func (h maxHeap) findIndexByKey(key string) int {
-	for i, kv := range h {
-		if kv.Key == key {
-			return i
-		}
-	}
-	return -1
-}
-
 func (h *maxHeap) incValue(key string) bool {
-	if index := h.findIndexByKey(key); index != -1 {
-		(*h)[index].Value++
+	if index, ok := h.index[key]; ok {
+		h.entries[index].Value++
 		heap.Fix(h, index)
 		return true
 	}
@@ -68,10 +70,44 @@ func (h *maxHeap) IncOrPush(key string) {
 	}
 }
 
-func (h maxHeap) GetMaxValuePairs(n int) []KeyValuePair {
-	top3 := make([]KeyValuePair, 0)
-	for i := 0; i < n && i < len(h); i++ {
-		top3 = append(top3, h[i])
+// GetMaxValuePairs returns n pairs with the largest values, in decreasing
+// order of value. Children of a heap entry are never larger than the entry,
+// hence the next largest pair is always a child of the pairs taken so far.
+// Tracking those candidates in their own heap makes it O(n log n),
+// irrespective of number of keys.
+func (h *maxHeap) GetMaxValuePairs(n int) []KeyValuePair {
+	top := make([]KeyValuePair, 0)
+	if h.Len() == 0 {
+		return top
 	}
-	return top3
+	candidates := &candidateHeap{entries: h.entries, indices: []int{0}}
+	for len(top) < n && candidates.Len() > 0 {
+		i := heap.Pop(candidates).(int)
+		top = append(top, h.entries[i])
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < h.Len() {
+				heap.Push(candidates, child)
+			}
+		}
+	}
+	return top
+}
+
+// candidateHeap is a max heap of positions in entries
+type candidateHeap struct {
+	entries []KeyValuePair
+	indices []int
+}
+
+func (c *candidateHeap) Len() int { return len(c.indices) }
+func (c *candidateHeap) Less(i, j int) bool {
+	return c.entries[c.indices[i]].Value > c.entries[c.indices[j]].Value
+}
+func (c *candidateHeap) Swap(i, j int)      { c.indices[i], c.indices[j] = c.indices[j], c.indices[i] }
+func (c *candidateHeap) Push(x interface{}) { c.indices = append(c.indices, x.(int)) }
+func (c *candidateHeap) Pop() interface{} {
+	n := len(c.indices)
+	i := c.indices[n-1]
+	c.indices = c.indices[0 : n-1]
+	return i
 }
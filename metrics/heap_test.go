@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -28,3 +30,95 @@ func TestHeap(t *testing.T) {
 		t.Errorf("Expected third top value pair to be {Key: 'facebook.com', Value: 1}, but got %v", top3[2])
 	}
 }
+
+func TestHeap_GetMaxValuePairs_order(t *testing.T) {
+	h := NewHeap()
+	counts := map[string]int{"a": 1, "b": 7, "c": 3, "d": 5, "e": 2, "f": 6, "g": 4}
+	for key, count := range counts {
+		for i := 0; i < count; i++ {
+			h.IncOrPush(key)
+		}
+	}
+
+	got := h.GetMaxValuePairs(5)
+	expected := []KeyValuePair{{"b", 7}, {"f", 6}, {"d", 5}, {"g", 4}, {"c", 3}}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+	if got := h.GetMaxValuePairs(10); len(got) != len(counts) {
+		t.Errorf("Expected %d pairs, but got %d", len(counts), len(got))
+	}
+	if got := NewHeap().GetMaxValuePairs(3); len(got) != 0 {
+		t.Errorf("Expected no pairs from empty heap, but got %v", got)
+	}
+}
+
+func TestHeap_index(t *testing.T) {
+	h := NewHeap().(*maxHeap)
+	for i := 0; i < 1000; i++ {
+		h.IncOrPush(fmt.Sprintf("key%d", i%97))
+	}
+	for key, index := range h.index {
+		if h.entries[index].Key != key {
+			t.Fatalf("Expected %s at index %d, but found %s", key, index, h.entries[index].Key)
+		}
+	}
+	if len(h.index) != h.Len() {
+		t.Errorf("Expected %d keys in index, but got %d", h.Len(), len(h.index))
+	}
+}
+
+// BenchmarkHeap_IncOrPush increments existing keys of heaps of growing size.
+// Time per operation stays nearly flat as number of keys grows, unlike the
+// linear scan it replaced.
+func BenchmarkHeap_IncOrPush(b *testing.B) {
+	for _, size := range []int{1e3, 1e4, 1e5, 1e6} {
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			h := NewHeap()
+			keys := make([]string, size)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("domain%d.com", i)
+				h.IncOrPush(keys[i])
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.IncOrPush(keys[(i*7919)%size])
+			}
+		})
+	}
+}
+
+// BenchmarkHeap_linearScan measures the key lookup by scanning the entries,
+// as done before the index was introduced, for comparison
+func BenchmarkHeap_linearScan(b *testing.B) {
+	for _, size := range []int{1e3, 1e4, 1e5} {
+		b.Run(fmt.Sprintf("keys=%d", size), func(b *testing.B) {
+			h := NewHeap().(*maxHeap)
+			for i := 0; i < size; i++ {
+				h.IncOrPush(fmt.Sprintf("domain%d.com", i))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := fmt.Sprintf("domain%d.com", (i*7919)%size)
+				for j := range h.entries {
+					if h.entries[j].Key == key {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHeap_GetMaxValuePairs(b *testing.B) {
+	h := NewHeap()
+	for i := 0; i < 1e5; i++ {
+		for j := 0; j <= i%10; j++ {
+			h.IncOrPush(fmt.Sprintf("domain%d.com", i))
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.GetMaxValuePairs(10)
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// worker runs a background loop until it is stopped
+type worker struct {
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// start runs loop in a goroutine, loop must return once stop is closed.
+// Calling start more than once has no effect.
+func (w *worker) start(loop func(stop <-chan struct{})) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done != nil || w.stopped {
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		loop(w.stop)
+	}()
+}
+
+// shutdown signals the loop to stop and waits for it to return, or for ctx
+// to be done
+func (w *worker) shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.stopped {
+		w.stopped = true
+		if w.stop != nil {
+			close(w.stop)
+		}
+	}
+	done := w.done
+	w.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,66 +1,344 @@
 package metrics
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thenilesh/url-shortner/store"
+)
+
+var (
+	ErrCollectorNotFound = errors.New("collector not found")
+	ErrCollectorExists   = errors.New("collector already registered")
+	ErrAlreadyStarted    = errors.New("metrics already started")
+)
+
+// DomainShortens is the collector of short paths created per target domain
+const DomainShortens = "domain_shortens"
+
 type Metrics interface {
+	// Start starts the registered collectors and click tracker
 	Start()
-	GetCollector(name string) Collector
+	// Stop drains the pending increments and clicks, and terminates their
+	// goroutines. It returns ctx error if draining is not done in time.
+	Stop(ctx context.Context) error
+	// RegisterCollector registers c by name, it must be called before Start
+	RegisterCollector(name string, c Collector) error
+	GetCollector(name string) (Collector, error)
+	GetClickTracker() ClickTracker
+	GetCounter(name string) *CounterVec
+	GetHistogram(name string) *HistogramVec
+	// WritePrometheus writes all the metrics in prometheus text exposition format
+	WritePrometheus(w io.Writer) error
 }
 
 type metrics struct {
-	collectors map[string]Collector
+	mu           sync.RWMutex
+	started      bool
+	collectors   map[string]Collector
+	clickTracker ClickTracker
+	counters     map[string]*CounterVec
+	histograms   map[string]*HistogramVec
+	// promMetrics are written in the order of registration
+	promMetrics []promMetric
+}
+
+const (
+	// DefaultCollectorBufferSize is the buffer size of default collectors
+	DefaultCollectorBufferSize = 1000
+	// TopDomainsBucketWidth is the granularity of windowed domain_shortens
+	TopDomainsBucketWidth = 5 * time.Minute
+	// TopDomainsRetention is the largest window of domain_shortens
+	TopDomainsRetention = 7 * 24 * time.Hour
+)
+
+// Option customizes metrics created by NewMetrics
+type Option func(m *metrics)
+
+// WithCollector registers collector c by name, replacing the default
+// collector registered by the same name, if any.
+// Use NewSpaceSavingCollector for bounded memory.
+func WithCollector(name string, c Collector) Option {
+	return func(m *metrics) {
+		m.collectors[name] = c
+	}
 }
 
-func NewMetrics() Metrics {
+// NewMetrics creates metrics, clicks are aggregated in clickStore
+func NewMetrics(clickStore store.CounterStore, opts ...Option) Metrics {
 	m := &metrics{
-		// TODO: Externalize registration of collectors
 		collectors: map[string]Collector{
-			"domain_shortens": newCollector(10),
+			DomainShortens: NewWindowedCollector(0, TopDomainsBucketWidth, TopDomainsRetention, DefaultCollectorBufferSize, OverflowDrop),
 		},
+		clickTracker: newClickTracker(clickStore, 1000),
+		counters:     make(map[string]*CounterVec),
+		histograms:   make(map[string]*HistogramVec),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.registerCounter(NewCounterVec(ShortensTotal, "Number of short paths created."))
+	m.registerCounter(NewCounterVec(RedirectsTotal, "Number of redirects to target URLs."))
+	m.registerCounter(NewCounterVec(ShortPathAttemptsTotal, "Number of generated short paths tried, by result: available or collision.", "result"))
+	m.registerCounter(NewCounterVec(HTTPErrorsTotal, "Number of HTTP responses with error status.", "route", "method", "status"))
+	m.registerHistogram(NewHistogramVec(HTTPRequestDuration, "Latency of HTTP handlers.", DefaultBuckets, "route", "method"))
+	m.registerHistogram(NewHistogramVec(StoreOperationDuration, "Latency of store operations.", DefaultBuckets, "store", "operation"))
+	m.promMetrics = append(m.promMetrics, &collectorGauge{
+		name:      "url_shortner_top_domain_shortens",
+		help:      "Number of short paths created for the top domains.",
+		labelName: "domain",
+		collector: m.collectors[DomainShortens],
+		n:         10,
+	})
+	m.promMetrics = append(m.promMetrics, &collectorDrops{metrics: m})
 	return m
 }
 
+func (m *metrics) registerCounter(c *CounterVec) {
+	m.counters[c.name] = c
+	m.promMetrics = append(m.promMetrics, c)
+}
+
+func (m *metrics) registerHistogram(h *HistogramVec) {
+	m.histograms[h.name] = h
+	m.promMetrics = append(m.promMetrics, h)
+}
+
 func (m *metrics) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = true
 	for _, c := range m.collectors {
 		c.Start()
 	}
+	m.clickTracker.Start()
 }
 
-func (m *metrics) GetCollector(name string) Collector {
-	return m.collectors[name]
+func (m *metrics) Stop(ctx context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var errs []error
+	for name, c := range m.collectors {
+		if err := c.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop collector %s: %w", name, err))
+		}
+	}
+	if err := m.clickTracker.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("stop click tracker: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *metrics) RegisterCollector(name string, c Collector) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started {
+		return ErrAlreadyStarted
+	}
+	if _, ok := m.collectors[name]; ok {
+		return fmt.Errorf("%w: %s", ErrCollectorExists, name)
+	}
+	m.collectors[name] = c
+	return nil
+}
+
+func (m *metrics) GetCollector(name string) (Collector, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.collectors[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectorNotFound, name)
+	}
+	return c, nil
+}
+
+func (m *metrics) GetClickTracker() ClickTracker {
+	return m.clickTracker
+}
+
+func (m *metrics) GetCounter(name string) *CounterVec {
+	return m.counters[name]
+}
+
+func (m *metrics) GetHistogram(name string) *HistogramVec {
+	return m.histograms[name]
+}
+
+func (m *metrics) WritePrometheus(w io.Writer) error {
+	for _, pm := range m.promMetrics {
+		if err := pm.writeTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type Collector interface {
 	Start()
+	// Stop processes the buffered keys and terminates the goroutine
+	// started by Start
+	Stop(ctx context.Context) error
 	Inc(key string)
 	GetMaxValuePairs(n int) []KeyValuePair
 }
 
+// OverflowPolicy decides what Inc does when buffer of collector is full
+type OverflowPolicy int
+
+const (
+	// OverflowDrop drops the key and counts it as dropped
+	OverflowDrop OverflowPolicy = iota
+	// OverflowBlock blocks Inc until there is room in buffer
+	OverflowBlock
+)
+
+// ParseOverflowPolicy parses "drop" or "block"
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "drop":
+		return OverflowDrop, nil
+	case "block":
+		return OverflowBlock, nil
+	}
+	return 0, fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// dropCounter is implemented by collectors which may drop keys
+type dropCounter interface {
+	Dropped() int64
+}
+
+// keyCounter counts keys for collector, it must be safe for concurrent use
+type keyCounter interface {
+	IncOrPush(key string)
+	GetMaxValuePairs(n int) []KeyValuePair
+}
+
+// collector counts keys in a keyCounter. Keys are buffered by Inc and
+// counted by the goroutine started by Start, so that callers do not wait on
+// counting.
 type collector struct {
 	bufferChan chan string
-	heap       Heap
+	policy     OverflowPolicy
+	// closed is closed by Stop, Inc drops keys afterwards
+	closed    chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Int64
+	counter   keyCounter
+	worker    worker
 }
 
-func newCollector(bufferSize int) Collector {
-	c := &collector{
+// NewHeapCollector creates a Collector that counts every key exactly in
+// memory. Up to bufferSize keys wait to be counted, policy decides what Inc
+// does beyond that.
+func NewHeapCollector(bufferSize int, policy OverflowPolicy) Collector {
+	return newCollector(&syncHeap{heap: NewHeap()}, bufferSize, policy)
+}
+
+func newCollector(counter keyCounter, bufferSize int, policy OverflowPolicy) *collector {
+	return &collector{
 		bufferChan: make(chan string, bufferSize),
-		heap:       NewHeap(),
+		policy:     policy,
+		closed:     make(chan struct{}),
+		counter:    counter,
 	}
-	return c
 }
 
 func (c *collector) Start() {
-	// TODO: introduce stop channel
-	go func() {
-		for domain := range c.bufferChan {
-			c.heap.IncOrPush(domain)
+	c.worker.start(func(stop <-chan struct{}) {
+		for {
+			select {
+			case key := <-c.bufferChan:
+				c.incOrPush(key)
+			case <-stop:
+				c.drain()
+				return
+			}
+		}
+	})
+}
+
+func (c *collector) drain() {
+	for {
+		select {
+		case key := <-c.bufferChan:
+			c.incOrPush(key)
+		default:
+			return
 		}
-	}()
+	}
+}
+
+func (c *collector) incOrPush(key string) {
+	c.counter.IncOrPush(key)
+}
+
+func (c *collector) Stop(ctx context.Context) error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.worker.shutdown(ctx)
 }
 
 func (c *collector) Inc(key string) {
-	c.heap.IncOrPush(key)
+	if !enqueue(c.bufferChan, key, c.policy, c.closed) {
+		c.dropped.Add(1)
+	}
+}
+
+// enqueue sends item to buffer unless closed is closed, policy decides
+// whether to drop or wait when buffer is full. It returns false if the item
+// is dropped.
+func enqueue[T any](buffer chan<- T, item T, policy OverflowPolicy, closed <-chan struct{}) bool {
+	select {
+	case <-closed:
+		return false
+	default:
+	}
+	if policy == OverflowBlock {
+		select {
+		case buffer <- item:
+			return true
+		case <-closed:
+			return false
+		}
+	}
+	select {
+	case buffer <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// Dropped returns number of keys dropped by Inc
+func (c *collector) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// GetMaxValuePairs returns a snapshot of the top pairs, keys still in
+// buffer are not counted yet
+func (c *collector) GetMaxValuePairs(n int) []KeyValuePair {
+	return c.counter.GetMaxValuePairs(n)
+}
+
+// syncHeap guards heap for concurrent increments and reads
+type syncHeap struct {
+	mu   sync.Mutex
+	heap Heap
+}
+
+func (h *syncHeap) IncOrPush(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.heap.IncOrPush(key)
 }
 
-func (c collector) GetMaxValuePairs(n int) []KeyValuePair {
-	return c.heap.GetMaxValuePairs(n)
+func (h *syncHeap) GetMaxValuePairs(n int) []KeyValuePair {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.heap.GetMaxValuePairs(n)
 }
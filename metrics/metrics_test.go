@@ -1,22 +1,34 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/thenilesh/url-shortner/store"
 )
 
 func TestMetrics(t *testing.T) {
-	m := NewMetrics()
+	m := NewMetrics(store.NewGoMapCounterStore())
 	m.Start()
 
-	collector := m.GetCollector("domain_shortens")
-	if collector == nil {
-		t.Errorf("Expected collector to not be nil")
+	collector, err := m.GetCollector("domain_shortens")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
 	}
 
 	collector.Inc("example.com")
 	collector.Inc("example.com")
 	collector.Inc("example.org")
 	collector.Inc("example.net")
+	// Stop drains the buffered keys
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
 
 	pairs := collector.GetMaxValuePairs(2)
 	if len(pairs) != 2 {
@@ -29,3 +41,198 @@ func TestMetrics(t *testing.T) {
 		t.Errorf("Expected pair 1 to be {\"example.org\", 1}, got %v", pairs[1])
 	}
 }
+
+func TestMetrics_WithCollector(t *testing.T) {
+	c := NewSpaceSavingCollector(10)
+	m := NewMetrics(store.NewGoMapCounterStore(), WithCollector("domain_shortens", c))
+
+	if got, _ := m.GetCollector("domain_shortens"); got != c {
+		t.Errorf("Expected domain_shortens to be the registered collector")
+	}
+	c.Inc("example.com")
+
+	var buf strings.Builder
+	if err := m.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), `url_shortner_top_domain_shortens{domain="example.com"} 1`) {
+		t.Errorf("Expected top domain gauge to use registered collector, got %s", buf.String())
+	}
+}
+
+func TestMetrics_RegisterCollector(t *testing.T) {
+	m := NewMetrics(store.NewGoMapCounterStore())
+
+	if _, err := m.GetCollector("unknown"); !errors.Is(err, ErrCollectorNotFound) {
+		t.Errorf("Expected ErrCollectorNotFound, got %v", err)
+	}
+
+	c := NewSpaceSavingCollector(10)
+	if err := m.RegisterCollector("referrers", c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got, err := m.GetCollector("referrers"); err != nil || got != c {
+		t.Errorf("Expected registered collector, got %v, %v", got, err)
+	}
+	if err := m.RegisterCollector("referrers", c); !errors.Is(err, ErrCollectorExists) {
+		t.Errorf("Expected ErrCollectorExists, got %v", err)
+	}
+
+	m.Start()
+	if err := m.RegisterCollector("late", c); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("Expected ErrAlreadyStarted, got %v", err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestCollector_Stop(t *testing.T) {
+	c := NewHeapCollector(10, OverflowDrop).(*collector)
+	c.Start()
+	for i := 0; i < 5; i++ {
+		c.bufferChan <- "example.com"
+	}
+
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Buffered keys are processed before Stop returns
+	pairs := c.GetMaxValuePairs(1)
+	if len(pairs) != 1 || pairs[0].Value != 5 {
+		t.Errorf("Expected example.com with 5, got %v", pairs)
+	}
+	// Stop is idempotent
+	if err := c.Stop(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestCollector_Stop_notStarted(t *testing.T) {
+	c := NewHeapCollector(10, OverflowDrop)
+	if err := c.Stop(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestCollector_Inc_drop(t *testing.T) {
+	c := NewHeapCollector(5, OverflowDrop).(*collector)
+	// Not started, hence nobody drains the buffer
+	for i := 0; i < 8; i++ {
+		c.Inc("example.com")
+	}
+	if c.Dropped() != 3 {
+		t.Errorf("Expected 3 dropped, got %d", c.Dropped())
+	}
+
+	c.Start()
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pairs := c.GetMaxValuePairs(1)
+	if len(pairs) != 1 || pairs[0].Value != 5 {
+		t.Errorf("Expected example.com with 5, got %v", pairs)
+	}
+	// Keys are dropped after Stop
+	c.Inc("example.com")
+	if c.Dropped() != 4 {
+		t.Errorf("Expected 4 dropped, got %d", c.Dropped())
+	}
+}
+
+func TestCollector_Inc_block(t *testing.T) {
+	c := NewHeapCollector(1, OverflowBlock).(*collector)
+	c.Inc("example.com")
+
+	done := make(chan struct{})
+	go func() {
+		c.Inc("example.com")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected Inc to block while buffer is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	c.Start()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Inc to return once buffer is drained")
+	}
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if pairs := c.GetMaxValuePairs(1); len(pairs) != 1 || pairs[0].Value != 2 {
+		t.Errorf("Expected example.com with 2, got %v", pairs)
+	}
+	if c.Dropped() != 0 {
+		t.Errorf("Expected nothing dropped, got %d", c.Dropped())
+	}
+
+	// Inc does not block forever once stopped
+	c.Inc("example.com")
+	c.Inc("example.com")
+	if c.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped, got %d", c.Dropped())
+	}
+}
+
+// TestCollector_concurrent increments and reads concurrently, run with
+// -race to detect unsynchronized access of heap
+func TestCollector_concurrent(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowDrop, OverflowBlock} {
+		c := NewHeapCollector(16, policy).(*collector)
+		c.Start()
+
+		const writers, incs = 20, 500
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < incs; j++ {
+					c.Inc(fmt.Sprintf("domain%d.com", (i+j)%10))
+				}
+			}(i)
+		}
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					c.GetMaxValuePairs(3)
+				}
+			}()
+		}
+		wg.Wait()
+		if err := c.Stop(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var total int64
+		for _, pair := range c.GetMaxValuePairs(10) {
+			total += int64(pair.Value)
+		}
+		if total+c.Dropped() != writers*incs {
+			t.Errorf("policy %d: expected %d counted or dropped, got %d counted and %d dropped",
+				policy, writers*incs, total, c.Dropped())
+		}
+		if policy == OverflowBlock && c.Dropped() != 0 {
+			t.Errorf("Expected nothing dropped with block policy, got %d", c.Dropped())
+		}
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	if p, err := ParseOverflowPolicy("block"); err != nil || p != OverflowBlock {
+		t.Errorf("Expected OverflowBlock, got %v, %v", p, err)
+	}
+	if p, err := ParseOverflowPolicy("drop"); err != nil || p != OverflowDrop {
+		t.Errorf("Expected OverflowDrop, got %v, %v", p, err)
+	}
+	if _, err := ParseOverflowPolicy("wait"); err == nil {
+		t.Errorf("Expected error for unknown policy")
+	}
+}
@@ -0,0 +1,305 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Names of the metrics exposed in prometheus format
+const (
+	ShortensTotal          = "url_shortner_shortens_total"
+	RedirectsTotal         = "url_shortner_redirects_total"
+	HTTPErrorsTotal        = "url_shortner_http_errors_total"
+	HTTPRequestDuration    = "url_shortner_http_request_duration_seconds"
+	StoreOperationDuration = "url_shortner_store_operation_duration_seconds"
+	CollectorDroppedTotal  = "url_shortner_collector_dropped_total"
+	ShortPathAttemptsTotal = "url_shortner_short_path_attempts_total"
+)
+
+// DefaultBuckets are upper bounds of histogram buckets in seconds,
+// suitable to measure latency of handlers and stores
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// promMetric is a metric that can be written in prometheus text format
+type promMetric interface {
+	writeTo(w io.Writer) error
+}
+
+// CounterVec is a prometheus counter partitioned by label values
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	series     map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     make(map[string]*counterSeries),
+	}
+	if len(labelNames) == 0 {
+		// Counter without labels is exposed even before first increment
+		c.series[""] = &counterSeries{}
+	}
+	return c
+}
+
+// Inc increments the counter of the label values by one.
+// Label values must be given in the order of label names.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	key := seriesKey(c.labelNames, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{labelValues: labelValues}
+		c.series[key] = s
+	}
+	s.value += delta
+}
+
+// Value returns the current value of the counter of the label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := seriesKey(c.labelNames, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) writeTo(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeHeader(w, c.name, c.help, "counter"); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		labels := formatLabels(c.labelNames, s.labelValues, "", "")
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistogramVec is a prometheus histogram partitioned by label values
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	// counts[i] is number of observations falling in buckets[i], not cumulative
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates histogram with buckets sorted in increasing order
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*histogramSeries),
+	}
+}
+
+// Observe adds the value to the histogram of the label values.
+// Label values must be given in the order of label names.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := seriesKey(h.labelNames, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count returns the number of observations of the label values
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	key := seriesKey(h.labelNames, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upperBound := range h.buckets {
+			cumulative += s.counts[i]
+			labels := formatLabels(h.labelNames, s.labelValues, "le", formatValue(upperBound))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels, cumulative); err != nil {
+				return err
+			}
+		}
+		labels := formatLabels(h.labelNames, s.labelValues, "le", "+Inf")
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels, s.count); err != nil {
+			return err
+		}
+		labels = formatLabels(h.labelNames, s.labelValues, "", "")
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatValue(s.sum)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectorGauge exposes top values of a Collector as a gauge
+type collectorGauge struct {
+	name      string
+	help      string
+	labelName string
+	collector Collector
+	n         int
+}
+
+func (g *collectorGauge) writeTo(w io.Writer) error {
+	if err := writeHeader(w, g.name, g.help, "gauge"); err != nil {
+		return err
+	}
+	for _, kv := range g.collector.GetMaxValuePairs(g.n) {
+		labels := formatLabels([]string{g.labelName}, []string{kv.Key}, "", "")
+		if _, err := fmt.Fprintf(w, "%s%s %d\n", g.name, labels, kv.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectorDrops exposes keys dropped by the registered collectors as a
+// counter
+type collectorDrops struct {
+	metrics *metrics
+}
+
+func (d *collectorDrops) writeTo(w io.Writer) error {
+	if err := writeHeader(w, CollectorDroppedTotal, "Number of keys dropped by collectors with full buffer.", "counter"); err != nil {
+		return err
+	}
+	d.metrics.mu.RLock()
+	drops := make(map[string]int64)
+	for name, c := range d.metrics.collectors {
+		if dc, ok := c.(dropCounter); ok {
+			drops[name] = dc.Dropped()
+		}
+	}
+	d.metrics.mu.RUnlock()
+	names := make([]string, 0, len(drops))
+	for name := range drops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		labels := formatLabels([]string{"collector"}, []string{name}, "", "")
+		if _, err := fmt.Fprintf(w, "%s%s %d\n", CollectorDroppedTotal, labels, drops[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, name string, help string, metricType string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, metricType)
+	return err
+}
+
+// seriesKey identifies the series of the label values, it panics on
+// mismatch of label count just like prometheus client does
+func seriesKey(labelNames []string, labelValues []string) string {
+	if len(labelNames) != len(labelValues) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\x00")
+}
+
+// formatLabels formats labels as {name="value",...}, extraName is
+// appended if it is not empty
+func formatLabels(names []string, values []string, extraName string, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, escapeLabelValue(extraValue)))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thenilesh/url-shortner/store"
+)
+
+func TestCounterVec(t *testing.T) {
+	c := NewCounterVec("errors_total", "Number of errors.", "route", "status")
+	c.Inc("/{id}", "404")
+	c.Inc("/{id}", "404")
+	c.Add(3, "/", "500")
+	c.Inc(`/"quoted"`, "400")
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.writeTo(&buf))
+	assert.Equal(t, `# HELP errors_total Number of errors.
+# TYPE errors_total counter
+errors_total{route="/",status="500"} 3
+errors_total{route="/\"quoted\"",status="400"} 1
+errors_total{route="/{id}",status="404"} 2
+`, buf.String())
+	assert.Equal(t, float64(2), c.Value("/{id}", "404"))
+	assert.Panics(t, func() { c.Inc("/") })
+}
+
+func TestCounterVec_withoutLabels(t *testing.T) {
+	c := NewCounterVec("shortens_total", "Number of shortens.")
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.writeTo(&buf))
+	assert.Equal(t, "# HELP shortens_total Number of shortens.\n# TYPE shortens_total counter\nshortens_total 0\n", buf.String())
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "get")
+	h.Observe(0.1, "get")
+	h.Observe(0.5, "get")
+	h.Observe(2, "get")
+
+	var buf bytes.Buffer
+	assert.NoError(t, h.writeTo(&buf))
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 2
+latency_seconds_bucket{op="get",le="1"} 3
+latency_seconds_bucket{op="get",le="+Inf"} 4
+latency_seconds_sum{op="get"} 2.65
+latency_seconds_count{op="get"} 4
+`, buf.String())
+	assert.Equal(t, uint64(4), h.Count("get"))
+}
+
+func TestMetrics_WritePrometheus(t *testing.T) {
+	m := NewMetrics(store.NewGoMapCounterStore())
+	m.Start()
+	m.GetCounter(ShortensTotal).Inc()
+	c, err := m.GetCollector(DomainShortens)
+	assert.NoError(t, err)
+	c.Inc("example.com")
+	assert.NoError(t, m.Stop(context.Background()))
+
+	var buf bytes.Buffer
+	assert.NoError(t, m.WritePrometheus(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "url_shortner_shortens_total 1\n")
+	assert.Contains(t, out, "# TYPE url_shortner_http_request_duration_seconds histogram\n")
+	assert.Contains(t, out, `url_shortner_top_domain_shortens{domain="example.com"} 1`+"\n")
+	assert.Contains(t, out, `url_shortner_collector_dropped_total{collector="domain_shortens"} 0`+"\n")
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		assert.Regexp(t, `^(# (HELP|TYPE) \w+ .+|\w+(\{.*\})? \S+)$`, line)
+	}
+}
+
+func TestInstrumentedKVStore(t *testing.T) {
+	ctx := context.Background()
+	h := NewHistogramVec(StoreOperationDuration, "", DefaultBuckets, "store", "operation")
+	kv := NewInstrumentedKVStore(store.NewGoMapStore(), "target", h)
+
+	_, err := kv.PutIfAbsent(ctx, "key1", "value1", 0)
+	assert.NoError(t, err)
+	val, err := kv.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+	_, err = kv.Get(ctx, "notfoundkey")
+	assert.Equal(t, store.ErrKeyNotFound, err)
+
+	assert.Equal(t, uint64(1), h.Count("target", "put_if_absent"))
+	assert.Equal(t, uint64(2), h.Count("target", "get"))
+}
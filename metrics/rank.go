@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/thenilesh/url-shortner/store"
+)
+
+// rankStoreTimeout bounds every call to rank store, Collector methods
+// don't take a context
+const rankStoreTimeout = time.Second
+
+// NewRankCollector creates a Collector that counts keys as scores of key in
+// rankStore. With a redis RankStore, counts are shared by every instance and
+// survive restarts. Buffering and policy are same as NewHeapCollector.
+func NewRankCollector(rankStore store.RankStore, key string, bufferSize int, policy OverflowPolicy) Collector {
+	return newCollector(&rankCounter{rankStore: rankStore, key: key}, bufferSize, policy)
+}
+
+// rankCounter is a keyCounter backed by a RankStore
+type rankCounter struct {
+	rankStore store.RankStore
+	key       string
+	failed    atomic.Int64
+}
+
+func (r *rankCounter) IncOrPush(member string) {
+	ctx, cancel := context.WithTimeout(context.Background(), rankStoreTimeout)
+	defer cancel()
+	if err := r.rankStore.IncrScore(ctx, r.key, member, 1); err != nil {
+		r.failed.Add(1)
+	}
+}
+
+// GetMaxValuePairs returns no pairs if rank store fails
+func (r *rankCounter) GetMaxValuePairs(n int) []KeyValuePair {
+	ctx, cancel := context.WithTimeout(context.Background(), rankStoreTimeout)
+	defer cancel()
+	ranked, err := r.rankStore.TopN(ctx, r.key, n)
+	if err != nil {
+		r.failed.Add(1)
+		return []KeyValuePair{}
+	}
+	pairs := make([]KeyValuePair, 0, len(ranked))
+	for _, m := range ranked {
+		pairs = append(pairs, KeyValuePair{Key: m.Member, Value: int(m.Score)})
+	}
+	return pairs
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thenilesh/url-shortner/store"
+)
+
+func TestRankCollector(t *testing.T) {
+	rankStore := store.NewGoMapRankStore()
+	// Two instances sharing the store
+	c1 := NewRankCollector(rankStore, DomainShortens, 10, OverflowBlock)
+	c2 := NewRankCollector(rankStore, DomainShortens, 10, OverflowBlock)
+	c1.Start()
+	c2.Start()
+
+	c1.Inc("example.com")
+	c1.Inc("example.org")
+	c2.Inc("example.com")
+	c2.Inc("example.net")
+	c2.Inc("example.com")
+	assert.NoError(t, c1.Stop(context.Background()))
+	assert.NoError(t, c2.Stop(context.Background()))
+
+	expected := []KeyValuePair{{"example.com", 3}, {"example.org", 1}}
+	assert.Equal(t, expected, c1.GetMaxValuePairs(2))
+	assert.Equal(t, expected, c2.GetMaxValuePairs(2))
+}
+
+func TestRankCollector_storeFailure(t *testing.T) {
+	c := NewRankCollector(failingRankStore{}, DomainShortens, 10, OverflowBlock).(*collector)
+	c.Start()
+	c.Inc("example.com")
+	assert.NoError(t, c.Stop(context.Background()))
+
+	assert.Equal(t, []KeyValuePair{}, c.GetMaxValuePairs(3))
+	assert.Equal(t, int64(2), c.counter.(*rankCounter).failed.Load())
+}
+
+type failingRankStore struct{}
+
+func (failingRankStore) IncrScore(ctx context.Context, key string, member string, delta int64) error {
+	return errors.New("connection refused")
+}
+
+func (failingRankStore) TopN(ctx context.Context, key string, n int) ([]store.RankedMember, error) {
+	return nil, errors.New("connection refused")
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+)
+
+// spaceSavingCollector tracks approximate top keys with Space-Saving
+// algorithm, using memory for at most capacity keys. When a new key arrives
+// and all the slots are taken, the key with the minimum count is evicted and
+// the new key inherits its count, so counts are overestimated by at most the
+// inherited error. Keys with a frequency above N/capacity, N being the total
+// of all increments, are guaranteed to be tracked.
+type spaceSavingCollector struct {
+	mu       sync.Mutex
+	capacity int
+	counters *minCounterHeap
+}
+
+type spaceSavingCounter struct {
+	key   string
+	count int
+	// err is the count inherited from evicted key, upper bound of
+	// overestimation
+	err int
+}
+
+// NewSpaceSavingCollector creates a Collector that keeps at most capacity
+// keys in memory. Counts reported are approximate once number of distinct
+// keys exceeds capacity.
+func NewSpaceSavingCollector(capacity int) Collector {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &spaceSavingCollector{
+		capacity: capacity,
+		counters: &minCounterHeap{index: make(map[string]int, capacity)},
+	}
+}
+
+func (c *spaceSavingCollector) Start() {}
+
+func (c *spaceSavingCollector) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (c *spaceSavingCollector) Inc(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := c.counters
+	if i, ok := h.index[key]; ok {
+		h.entries[i].count++
+		heap.Fix(h, i)
+		return
+	}
+	if h.Len() < c.capacity {
+		heap.Push(h, spaceSavingCounter{key: key, count: 1})
+		return
+	}
+	// Replace the minimum, which is always at the root
+	min := h.entries[0]
+	delete(h.index, min.key)
+	h.entries[0] = spaceSavingCounter{key: key, count: min.count + 1, err: min.count}
+	h.index[key] = 0
+	heap.Fix(h, 0)
+}
+
+// IncOrPush makes spaceSavingCollector a keyCounter, counting the key as Inc
+// does
+func (c *spaceSavingCollector) IncOrPush(key string) {
+	c.Inc(key)
+}
+
+func (c *spaceSavingCollector) GetMaxValuePairs(n int) []KeyValuePair {
+	c.mu.Lock()
+	counters := make([]spaceSavingCounter, len(c.counters.entries))
+	copy(counters, c.counters.entries)
+	c.mu.Unlock()
+
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].count != counters[j].count {
+			return counters[i].count > counters[j].count
+		}
+		return counters[i].key < counters[j].key
+	})
+	if n > len(counters) {
+		n = len(counters)
+	}
+	pairs := make([]KeyValuePair, 0, n)
+	for _, counter := range counters[:n] {
+		pairs = append(pairs, KeyValuePair{Key: counter.key, Value: counter.count})
+	}
+	return pairs
+}
+
+// minCounterHeap is a min heap of counters, indexed by key
+type minCounterHeap struct {
+	entries []spaceSavingCounter
+	index   map[string]int
+}
+
+func (h *minCounterHeap) Len() int           { return len(h.entries) }
+func (h *minCounterHeap) Less(i, j int) bool { return h.entries[i].count < h.entries[j].count }
+func (h *minCounterHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].key] = i
+	h.index[h.entries[j].key] = j
+}
+
+func (h *minCounterHeap) Push(x interface{}) {
+	counter := x.(spaceSavingCounter)
+	h.index[counter.key] = len(h.entries)
+	h.entries = append(h.entries, counter)
+}
+
+func (h *minCounterHeap) Pop() interface{} {
+	n := len(h.entries)
+	counter := h.entries[n-1]
+	h.entries = h.entries[0 : n-1]
+	delete(h.index, counter.key)
+	return counter
+}
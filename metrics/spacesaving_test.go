@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSpaceSavingCollector_exact(t *testing.T) {
+	c := NewSpaceSavingCollector(5)
+	c.Inc("example.com")
+	c.Inc("example.com")
+	c.Inc("example.org")
+	c.Inc("example.com")
+	c.Inc("example.org")
+	c.Inc("example.net")
+
+	got := c.GetMaxValuePairs(5)
+	expected := []KeyValuePair{{"example.com", 3}, {"example.org", 2}, {"example.net", 1}}
+	if fmt.Sprint(expected) != fmt.Sprint(got) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestSpaceSavingCollector_eviction(t *testing.T) {
+	c := NewSpaceSavingCollector(2)
+	c.Inc("a")
+	c.Inc("a")
+	c.Inc("b")
+	// c replaces b, the minimum, and inherits its count
+	c.Inc("c")
+
+	got := c.GetMaxValuePairs(3)
+	expected := []KeyValuePair{{"a", 2}, {"c", 2}}
+	if fmt.Sprint(expected) != fmt.Sprint(got) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+// TestSpaceSavingCollector_accuracy compares the top keys reported against
+// exact counts on a zipf distributed stream of keys
+func TestSpaceSavingCollector_accuracy(t *testing.T) {
+	const (
+		capacity = 100
+		distinct = 10000
+		total    = 200000
+		topN     = 10
+	)
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.2, 1, distinct-1)
+
+	c := NewSpaceSavingCollector(capacity)
+	exact := make(map[string]int)
+	for i := 0; i < total; i++ {
+		key := fmt.Sprintf("domain%d.com", zipf.Uint64())
+		c.Inc(key)
+		exact[key]++
+	}
+
+	exactTop := make([]KeyValuePair, 0, len(exact))
+	for key, count := range exact {
+		exactTop = append(exactTop, KeyValuePair{Key: key, Value: count})
+	}
+	sort.Slice(exactTop, func(i, j int) bool { return exactTop[i].Value > exactTop[j].Value })
+
+	got := c.GetMaxValuePairs(topN)
+	if len(got) != topN {
+		t.Fatalf("Expected %d pairs, but got %d", topN, len(got))
+	}
+	// Overestimation is bounded by total/capacity
+	maxErr := total / capacity
+	for i, pair := range got {
+		if pair.Key != exactTop[i].Key {
+			t.Errorf("Expected key %s at %d, but got %s", exactTop[i].Key, i, pair.Key)
+		}
+		diff := pair.Value - exact[pair.Key]
+		if diff < 0 || diff > maxErr {
+			t.Errorf("Expected count of %s within [%d, %d], but got %d",
+				pair.Key, exact[pair.Key], exact[pair.Key]+maxErr, pair.Value)
+		}
+		relErr := float64(diff) / float64(exact[pair.Key])
+		if relErr > 0.05 {
+			t.Errorf("Expected relative error of %s below 5%%, but got %.2f%%", pair.Key, relErr*100)
+		}
+	}
+}
+
+func TestSpaceSavingCollector_boundedMemory(t *testing.T) {
+	c := NewSpaceSavingCollector(50).(*spaceSavingCollector)
+	for i := 0; i < 10000; i++ {
+		c.Inc(fmt.Sprintf("domain%d.com", i))
+	}
+	if c.counters.Len() != 50 || len(c.counters.index) != 50 {
+		t.Errorf("Expected 50 counters, but got %d entries and %d indexed", c.counters.Len(), len(c.counters.index))
+	}
+}
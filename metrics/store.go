@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/thenilesh/url-shortner/store"
+)
+
+// instrumentedKVStore observes latency of every operation of the KVStore
+type instrumentedKVStore struct {
+	kv        store.KVStore
+	storeName string
+	histogram *HistogramVec
+}
+
+// NewInstrumentedKVStore wraps kv so that latency of its operations is
+// observed in histogram labelled with storeName and operation
+func NewInstrumentedKVStore(kv store.KVStore, storeName string, histogram *HistogramVec) store.KVStore {
+	return &instrumentedKVStore{
+		kv:        kv,
+		storeName: storeName,
+		histogram: histogram,
+	}
+}
+
+func (s *instrumentedKVStore) observe(operation string, start time.Time) {
+	s.histogram.Observe(time.Since(start).Seconds(), s.storeName, operation)
+}
+
+func (s *instrumentedKVStore) Put(ctx context.Context, key string, value string) error {
+	defer s.observe("put", time.Now())
+	return s.kv.Put(ctx, key, value)
+}
+
+func (s *instrumentedKVStore) PutIfAbsent(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	defer s.observe("put_if_absent", time.Now())
+	return s.kv.PutIfAbsent(ctx, key, value, ttl)
+}
+
+func (s *instrumentedKVStore) ReplaceIf(ctx context.Context, key string, old string, value string, ttl time.Duration) (bool, error) {
+	defer s.observe("replace_if", time.Now())
+	return s.kv.ReplaceIf(ctx, key, old, value, ttl)
+}
+
+func (s *instrumentedKVStore) Get(ctx context.Context, key string) (string, error) {
+	defer s.observe("get", time.Now())
+	return s.kv.Get(ctx, key)
+}
+
+func (s *instrumentedKVStore) Exists(ctx context.Context, key string) (bool, error) {
+	defer s.observe("exists", time.Now())
+	return s.kv.Exists(ctx, key)
+}
+
+func (s *instrumentedKVStore) Delete(ctx context.Context, key string) error {
+	defer s.observe("delete", time.Now())
+	return s.kv.Delete(ctx, key)
+}
+
+func (s *instrumentedKVStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	defer s.observe("ttl", time.Now())
+	return s.kv.TTL(ctx, key)
+}
+
+func (s *instrumentedKVStore) Scan(ctx context.Context, cursor string, count int) ([]string, string, error) {
+	defer s.observe("scan", time.Now())
+	return s.kv.Scan(ctx, cursor, count)
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// WindowedCollector is a Collector which also answers top keys counted
+// within a recent time window
+type WindowedCollector interface {
+	Collector
+	// GetMaxValuePairsInWindow returns n pairs with the largest values
+	// counted in the last window. window is rounded up to bucket width and
+	// capped at retention.
+	GetMaxValuePairsInWindow(window time.Duration, n int) []KeyValuePair
+}
+
+// windowedCollector is a collector counting keys in a windowCounter
+type windowedCollector struct {
+	*collector
+	window *windowCounter
+}
+
+// NewWindowedCollector creates a WindowedCollector keeping windowed counts
+// in buckets of bucketWidth for retention. All-time counts are kept for at
+// most capacity keys as NewSpaceSavingCollector does, or for every key as
+// NewHeapCollector does if capacity is 0. Buffering and policy are same as
+// NewHeapCollector.
+func NewWindowedCollector(capacity int, bucketWidth, retention time.Duration, bufferSize int, policy OverflowPolicy) WindowedCollector {
+	var allTime keyCounter = &syncHeap{heap: NewHeap()}
+	if capacity > 0 {
+		allTime = NewSpaceSavingCollector(capacity).(*spaceSavingCollector)
+	}
+	counter := newWindowCounter(allTime, bucketWidth, retention)
+	return &windowedCollector{
+		collector: newCollector(counter, bufferSize, policy),
+		window:    counter,
+	}
+}
+
+func (c *windowedCollector) GetMaxValuePairsInWindow(window time.Duration, n int) []KeyValuePair {
+	return c.window.getMaxValuePairsInWindow(window, n)
+}
+
+// windowCounter is a keyCounter which counts keys in a ring of buckets, each
+// spanning bucketWidth, along with the all-time counts of the wrapped
+// keyCounter. A key is counted in the bucket of the time it is counted,
+// which lags Inc only by the time it waits in the buffer. A bucket is reused
+// once it is older than retention, so memory is bounded by the number of
+// distinct keys seen during retention.
+type windowCounter struct {
+	allTime     keyCounter
+	mu          sync.Mutex
+	bucketWidth time.Duration
+	buckets     []windowBucket
+	now         func() time.Time
+}
+
+type windowBucket struct {
+	start  time.Time
+	counts map[string]int
+}
+
+func newWindowCounter(allTime keyCounter, bucketWidth, retention time.Duration) *windowCounter {
+	n := int((retention + bucketWidth - 1) / bucketWidth)
+	if n < 1 {
+		n = 1
+	}
+	return &windowCounter{
+		allTime:     allTime,
+		bucketWidth: bucketWidth,
+		buckets:     make([]windowBucket, n),
+		now:         time.Now,
+	}
+}
+
+func (c *windowCounter) IncOrPush(key string) {
+	c.allTime.IncOrPush(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	start := c.now().Truncate(c.bucketWidth)
+	b := &c.buckets[c.slot(start)]
+	if !b.start.Equal(start) || b.counts == nil {
+		// Evict counts of the old bucket sharing the slot
+		b.start = start
+		b.counts = make(map[string]int)
+	}
+	b.counts[key]++
+}
+
+func (c *windowCounter) GetMaxValuePairs(n int) []KeyValuePair {
+	return c.allTime.GetMaxValuePairs(n)
+}
+
+func (c *windowCounter) getMaxValuePairsInWindow(window time.Duration, n int) []KeyValuePair {
+	c.mu.Lock()
+	current := c.now().Truncate(c.bucketWidth)
+	retention := time.Duration(len(c.buckets)) * c.bucketWidth
+	if rem := window % c.bucketWidth; rem != 0 {
+		window += c.bucketWidth - rem
+	}
+	if window > retention {
+		window = retention
+	}
+	// The current bucket is partial, hence it is counted as one of the
+	// buckets in window
+	oldest := current.Add(-window).Add(c.bucketWidth)
+	totals := make(map[string]int)
+	for i := range c.buckets {
+		b := &c.buckets[i]
+		if b.counts == nil {
+			continue
+		}
+		if !b.start.After(current.Add(-retention)) {
+			b.counts = nil
+			continue
+		}
+		if b.start.Before(oldest) {
+			continue
+		}
+		for key, count := range b.counts {
+			totals[key] += count
+		}
+	}
+	c.mu.Unlock()
+
+	pairs := make([]KeyValuePair, 0, len(totals))
+	for key, count := range totals {
+		pairs = append(pairs, KeyValuePair{Key: key, Value: count})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Value != pairs[j].Value {
+			return pairs[i].Value > pairs[j].Value
+		}
+		return pairs[i].Key < pairs[j].Key
+	})
+	if n < len(pairs) {
+		pairs = pairs[:n]
+	}
+	return pairs
+}
+
+func (c *windowCounter) slot(start time.Time) int {
+	return int((start.UnixNano() / int64(c.bucketWidth)) % int64(len(c.buckets)))
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestWindowedCollector(now *time.Time) *windowedCollector {
+	c := NewWindowedCollector(100, time.Hour, 7*24*time.Hour, 100, OverflowDrop).(*windowedCollector)
+	c.window.now = func() time.Time { return *now }
+	return c
+}
+
+func TestWindowedCollector_GetMaxValuePairsInWindow(t *testing.T) {
+	now := time.Date(2023, 7, 1, 10, 30, 0, 0, time.UTC)
+	c := newTestWindowedCollector(&now)
+
+	// Burst of spam two days ago
+	now = now.Add(-48 * time.Hour)
+	for i := 0; i < 10; i++ {
+		c.Inc("spam.com")
+	}
+	// Not started, hence keys are counted in the bucket of drain
+	c.drain()
+	// Few hours ago
+	now = now.Add(45 * time.Hour)
+	c.Inc("example.org")
+	c.Inc("example.org")
+	c.drain()
+	// In the current hour
+	now = now.Add(3 * time.Hour)
+	c.Inc("example.com")
+	c.Inc("example.com")
+	c.Inc("example.com")
+	c.Inc("example.org")
+	c.drain()
+
+	tests := []struct {
+		window   time.Duration
+		n        int
+		expected []KeyValuePair
+	}{
+		{time.Hour, 3, []KeyValuePair{{"example.com", 3}, {"example.org", 1}}},
+		{24 * time.Hour, 3, []KeyValuePair{{"example.com", 3}, {"example.org", 3}}},
+		{7 * 24 * time.Hour, 3, []KeyValuePair{{"spam.com", 10}, {"example.com", 3}, {"example.org", 3}}},
+		{7 * 24 * time.Hour, 1, []KeyValuePair{{"spam.com", 10}}},
+	}
+	for _, tt := range tests {
+		got := c.GetMaxValuePairsInWindow(tt.window, tt.n)
+		if !reflect.DeepEqual(tt.expected, got) {
+			t.Errorf("window %s, n %d: expected %v, but got %v", tt.window, tt.n, tt.expected, got)
+		}
+	}
+
+	// All-time counts are kept by the wrapped counter
+	if got := c.GetMaxValuePairs(1); !reflect.DeepEqual([]KeyValuePair{{"spam.com", 10}}, got) {
+		t.Errorf("Expected all-time top to be spam.com, but got %v", got)
+	}
+}
+
+func TestWindowedCollector_GetMaxValuePairsInWindow_roundsUp(t *testing.T) {
+	now := time.Date(2023, 7, 1, 10, 2, 0, 0, time.UTC)
+	c := NewWindowedCollector(0, 5*time.Minute, time.Hour, 100, OverflowDrop).(*windowedCollector)
+	c.window.now = func() time.Time { return now }
+	c.Inc("older.com")
+	c.drain()
+	now = now.Add(5 * time.Minute)
+	c.Inc("previous.com")
+	c.drain()
+	now = now.Add(5 * time.Minute)
+	c.Inc("current.com")
+	c.drain()
+
+	// 7m takes two buckets of 5m, the current one and the previous one
+	expected := []KeyValuePair{{"current.com", 1}, {"previous.com", 1}}
+	if got := c.GetMaxValuePairsInWindow(7*time.Minute, 3); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+	expected = []KeyValuePair{{"current.com", 1}}
+	if got := c.GetMaxValuePairsInWindow(time.Minute, 3); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestWindowedCollector_eviction(t *testing.T) {
+	now := time.Date(2023, 7, 1, 10, 30, 0, 0, time.UTC)
+	c := newTestWindowedCollector(&now)
+	c.Inc("old.com")
+	c.drain()
+
+	// Past retention, the bucket is no longer counted
+	now = now.Add(7 * 24 * time.Hour)
+	if got := c.GetMaxValuePairsInWindow(7*24*time.Hour, 3); len(got) != 0 {
+		t.Errorf("Expected no pairs after retention, but got %v", got)
+	}
+	// and its counts are dropped
+	for _, b := range c.window.buckets {
+		if b.counts != nil {
+			t.Errorf("Expected expired bucket %s to be evicted", b.start)
+		}
+	}
+
+	// Slot of the expired bucket is reused
+	c.Inc("new.com")
+	c.drain()
+	got := c.GetMaxValuePairsInWindow(time.Hour, 3)
+	if !reflect.DeepEqual([]KeyValuePair{{"new.com", 1}}, got) {
+		t.Errorf("Expected only new.com, but got %v", got)
+	}
+}
+
+func TestWindowedCollector_Inc_async(t *testing.T) {
+	now := time.Date(2023, 7, 1, 10, 30, 0, 0, time.UTC)
+	c := NewWindowedCollector(0, time.Hour, 7*24*time.Hour, 2, OverflowDrop).(*windowedCollector)
+	c.window.now = func() time.Time { return now }
+	// Not started, hence Inc only buffers, and drops beyond the buffer
+	for i := 0; i < 3; i++ {
+		c.Inc("example.com")
+	}
+	if got := c.GetMaxValuePairsInWindow(time.Hour, 1); len(got) != 0 {
+		t.Errorf("Expected no pairs counted by Inc, but got %v", got)
+	}
+	if c.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped, got %d", c.Dropped())
+	}
+
+	// Buffered keys are counted, both in windows and all-time, before Stop
+	// returns
+	c.Start()
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []KeyValuePair{{"example.com", 2}}
+	if got := c.GetMaxValuePairsInWindow(time.Hour, 1); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+	if got := c.GetMaxValuePairs(1); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected all-time %v, but got %v", expected, got)
+	}
+	// Inc after Stop is dropped
+	c.Inc("example.com")
+	if c.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped, got %d", c.Dropped())
+	}
+}
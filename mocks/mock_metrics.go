@@ -3,6 +3,9 @@
 package mocks
 
 import (
+	context "context"
+	io "io"
+
 	mock "github.com/stretchr/testify/mock"
 	metrics "github.com/thenilesh/url-shortner/metrics"
 )
@@ -12,11 +15,31 @@ type Metrics struct {
 	mock.Mock
 }
 
+// GetClickTracker provides a mock function with given fields:
+func (_m *Metrics) GetClickTracker() metrics.ClickTracker {
+	ret := _m.Called()
+
+	var r0 metrics.ClickTracker
+	if rf, ok := ret.Get(0).(func() metrics.ClickTracker); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(metrics.ClickTracker)
+		}
+	}
+
+	return r0
+}
+
 // GetCollector provides a mock function with given fields: name
-func (_m *Metrics) GetCollector(name string) metrics.Collector {
+func (_m *Metrics) GetCollector(name string) (metrics.Collector, error) {
 	ret := _m.Called(name)
 
 	var r0 metrics.Collector
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (metrics.Collector, error)); ok {
+		return rf(name)
+	}
 	if rf, ok := ret.Get(0).(func(string) metrics.Collector); ok {
 		r0 = rf(name)
 	} else {
@@ -25,6 +48,58 @@ func (_m *Metrics) GetCollector(name string) metrics.Collector {
 		}
 	}
 
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCounter provides a mock function with given fields: name
+func (_m *Metrics) GetCounter(name string) *metrics.CounterVec {
+	ret := _m.Called(name)
+
+	var r0 *metrics.CounterVec
+	if rf, ok := ret.Get(0).(func(string) *metrics.CounterVec); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*metrics.CounterVec)
+		}
+	}
+
+	return r0
+}
+
+// GetHistogram provides a mock function with given fields: name
+func (_m *Metrics) GetHistogram(name string) *metrics.HistogramVec {
+	ret := _m.Called(name)
+
+	var r0 *metrics.HistogramVec
+	if rf, ok := ret.Get(0).(func(string) *metrics.HistogramVec); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*metrics.HistogramVec)
+		}
+	}
+
+	return r0
+}
+
+// RegisterCollector provides a mock function with given fields: name, c
+func (_m *Metrics) RegisterCollector(name string, c metrics.Collector) error {
+	ret := _m.Called(name, c)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, metrics.Collector) error); ok {
+		r0 = rf(name, c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
 	return r0
 }
 
@@ -33,6 +108,34 @@ func (_m *Metrics) Start() {
 	_m.Called()
 }
 
+// Stop provides a mock function with given fields: ctx
+func (_m *Metrics) Stop(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WritePrometheus provides a mock function with given fields: w
+func (_m *Metrics) WritePrometheus(w io.Writer) error {
+	ret := _m.Called(w)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(io.Writer) error); ok {
+		r0 = rf(w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewMetrics creates a new instance of Metrics. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMetrics(t interface {
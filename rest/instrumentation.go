@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thenilesh/url-shortner/metrics"
+)
+
+// NewMetricsMiddleware observes latency of every request and counts error
+// responses, labelled with the route template matched by mux
+func NewMetricsMiddleware(m metrics.Metrics) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			route := routeTemplate(r)
+			m.GetHistogram(metrics.HTTPRequestDuration).Observe(time.Since(start).Seconds(), route, r.Method)
+			if recorder.status >= http.StatusBadRequest {
+				m.GetCounter(metrics.HTTPErrorsTotal).Inc(route, r.Method, strconv.Itoa(recorder.status))
+			}
+		})
+	}
+}
+
+// routeTemplate returns path template of the matched route, so that
+// label values do not grow with every short path
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
+
+// statusRecorder remembers the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,35 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/thenilesh/url-shortner/metrics"
+	"github.com/thenilesh/url-shortner/rest"
+	"github.com/thenilesh/url-shortner/store"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	m := metrics.NewMetrics(store.NewGoMapCounterStore())
+	router := mux.NewRouter()
+	router.Use(rest.NewMetricsMiddleware(m))
+	router.HandleFunc("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods(http.MethodGet)
+
+	for _, path := range []string{"/abc", "/xyz", "/missing"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, uint64(3), m.GetHistogram(metrics.HTTPRequestDuration).Count("/{id}", "GET"))
+	assert.Equal(t, float64(1), m.GetCounter(metrics.HTTPErrorsTotal).Value("/{id}", "GET", "404"))
+	assert.Equal(t, float64(0), m.GetCounter(metrics.HTTPErrorsTotal).Value("/{id}", "GET", "200"))
+}
@@ -3,13 +3,31 @@ package rest
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/thenilesh/url-shortner/metrics"
 )
 
 type MetricsHandler interface {
+	// Get writes metrics in prometheus text exposition format
 	Get(w http.ResponseWriter, r *http.Request)
+	// TopDomains writes top n domains by number of short paths created,
+	// within window if set
+	TopDomains(w http.ResponseWriter, r *http.Request)
+}
+
+const (
+	defaultTopDomains = 3
+	maxTopDomains     = 100
+)
+
+// topDomainsWindows are the windows supported by TopDomains
+var topDomainsWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
 }
 
 type metricsHandler struct {
@@ -25,9 +43,49 @@ func NewMetricsHandler(log *logrus.Logger, m metrics.Metrics) MetricsHandler {
 }
 
 func (m *metricsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
+	if err := m.metrics.WritePrometheus(w); err != nil {
+		m.log.WithError(err).Error("Failed to write metrics")
+	}
+}
+
+func (m *metricsHandler) TopDomains(w http.ResponseWriter, r *http.Request) {
+	n := defaultTopDomains
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTopDomains {
+			http.Error(w, fmt.Sprintf("n must be between 1 and %d", maxTopDomains), http.StatusBadRequest)
+			return
+		}
+	}
+	collector, err := m.metrics.GetCollector(metrics.DomainShortens)
+	if err != nil {
+		m.log.WithError(err).Error("Failed to get collector")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var pairs []metrics.KeyValuePair
+	if v := r.URL.Query().Get("window"); v != "" {
+		window, ok := topDomainsWindows[v]
+		if !ok {
+			http.Error(w, "window must be one of 1h, 24h, 7d", http.StatusBadRequest)
+			return
+		}
+		wc, ok := collector.(metrics.WindowedCollector)
+		if !ok {
+			http.Error(w, "window is not supported", http.StatusBadRequest)
+			return
+		}
+		pairs = wc.GetMaxValuePairsInWindow(window, n)
+	} else {
+		pairs = collector.GetMaxValuePairs(n)
+	}
+
 	w.Header().Set("Content-Type", "text/plain")
-	for _, kv := range m.metrics.GetCollector("domain_shortens").GetMaxValuePairs(3) {
+	w.WriteHeader(http.StatusOK)
+	for _, kv := range pairs {
 		w.Write([]byte(kv.Key))
 		w.Write([]byte(": "))
 		w.Write([]byte(fmt.Sprintf("%d", kv.Value)))
@@ -1,12 +1,15 @@
 package rest_test
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/thenilesh/url-shortner/metrics"
 	"github.com/thenilesh/url-shortner/mocks"
 	"github.com/thenilesh/url-shortner/rest"
@@ -15,18 +18,88 @@ import (
 func TestMetricsHandler_Get(t *testing.T) {
 	log := logrus.New()
 	m := new(mocks.Metrics)
-	c := new(mocks.Collector)
 	handler := rest.NewMetricsHandler(log, m)
 
-	m.On("GetCollector", "domain_shortens").Return(c)
-	c.On("GetMaxValuePairs", 3).Return([]metrics.KeyValuePair{{Key: "test", Value: 1}})
+	m.On("WritePrometheus", mock.Anything).Run(func(args mock.Arguments) {
+		io.WriteString(args.Get(0).(io.Writer), "url_shortner_shortens_total 1\n")
+	}).Return(nil)
 	req, err := http.NewRequest("GET", "/metrics", nil)
 	assert.NoError(t, err)
 
 	rr := httptest.NewRecorder()
 	handler.Get(rr, req)
 
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "url_shortner_shortens_total 1\n", rr.Body.String())
+}
+
+func TestMetricsHandler_TopDomains(t *testing.T) {
+	log := logrus.New()
+	m := new(mocks.Metrics)
+	c := new(mocks.Collector)
+	handler := rest.NewMetricsHandler(log, m)
+
+	m.On("GetCollector", "domain_shortens").Return(c, nil)
+	c.On("GetMaxValuePairs", 3).Return([]metrics.KeyValuePair{{Key: "test", Value: 1}})
+	req, err := http.NewRequest("GET", "/metrics/top-domains", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.TopDomains(rr, req)
+
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.Equal(t, "text/plain", rr.Header().Get("Content-Type"))
 	assert.Equal(t, "test: 1\n", rr.Body.String())
 }
+
+func TestMetricsHandler_TopDomains_window(t *testing.T) {
+	log := logrus.New()
+	m := new(mocks.Metrics)
+	c := new(mocks.WindowedCollector)
+	handler := rest.NewMetricsHandler(log, m)
+
+	m.On("GetCollector", "domain_shortens").Return(c, nil)
+	c.On("GetMaxValuePairsInWindow", 24*time.Hour, 2).Return([]metrics.KeyValuePair{{Key: "a.com", Value: 5}, {Key: "b.com", Value: 2}})
+	req, err := http.NewRequest("GET", "/metrics/top-domains?window=24h&n=2", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.TopDomains(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "a.com: 5\nb.com: 2\n", rr.Body.String())
+	c.AssertExpectations(t)
+}
+
+func TestMetricsHandler_TopDomains_invalidParams(t *testing.T) {
+	log := logrus.New()
+	m := new(mocks.Metrics)
+	handler := rest.NewMetricsHandler(log, m)
+	m.On("GetCollector", "domain_shortens").Return(new(mocks.Collector), nil)
+
+	for _, query := range []string{"n=0", "n=abc", "n=101", "window=2h", "window=1h"} {
+		req, err := http.NewRequest("GET", "/metrics/top-domains?"+query, nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler.TopDomains(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestMetricsHandler_TopDomains_unknownCollector(t *testing.T) {
+	log := logrus.New()
+	m := new(mocks.Metrics)
+	handler := rest.NewMetricsHandler(log, m)
+
+	m.On("GetCollector", "domain_shortens").Return(nil, metrics.ErrCollectorNotFound)
+	req, err := http.NewRequest("GET", "/metrics/top-domains", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.TopDomains(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
@@ -0,0 +1,20 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDMiddleware sets a new request ID in the request context, under
+// RequestIDKey("requestID")
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := uuid.New().String()
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, RequestIDKey("requestID"), requestID)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,18 +1,34 @@
 package rest
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"github.com/thenilesh/url-shortner/metrics"
 	"github.com/thenilesh/url-shortner/svc"
 )
 
 const (
 	maxRequestBodySize = 104875 // 1 MB
+	defaultListLimit   = 20
+	maxListLimit       = 100
+	// Batch is limited by both the number of items and the body size
+	maxBatchSize            = 1000
+	maxBatchRequestBodySize = 10 * maxRequestBodySize
+	// Items of a batch created concurrently
+	batchConcurrency = 8
 )
 
 type RequestIDKey string
@@ -22,17 +38,25 @@ type ShortURLHandler interface {
 	Get(w http.ResponseWriter, r *http.Request)
 	Put(w http.ResponseWriter, r *http.Request)
 	Delete(w http.ResponseWriter, r *http.Request)
+	Stats(w http.ResponseWriter, r *http.Request)
+	// List writes a page of ShortURLs, filtered by q if set
+	List(w http.ResponseWriter, r *http.Request)
+	// Batch creates ShortURLs of the array in the body, writing the result
+	// of every item. Failure of an item does not fail the others.
+	Batch(w http.ResponseWriter, r *http.Request)
 }
 
 type shortURLHandler struct {
 	urlShortner svc.URLShortner
+	metrics     metrics.Metrics
 	log         *logrus.Logger
 }
 
-func NewShortURLHandler(log *logrus.Logger, urlShortner svc.URLShortner) ShortURLHandler {
+func NewShortURLHandler(log *logrus.Logger, urlShortner svc.URLShortner, m metrics.Metrics) ShortURLHandler {
 	return &shortURLHandler{
 		log:         log,
 		urlShortner: urlShortner,
+		metrics:     m,
 	}
 }
 
@@ -40,6 +64,47 @@ type ShortURL struct {
 	// Optional user provided short path
 	ShortPath string `json:"short_path"`
 	TargetURL string `json:"target_url"`
+	// Optional absolute expiry, mutually exclusive with TTLSeconds
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Optional expiry relative to the time of creation
+	TTLSeconds int64    `json:"ttl_seconds,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	// Optional style of the generated short path: random, words or counter.
+	// Ignored on update.
+	Style string `json:"style,omitempty"`
+	// Read only attributes, ignored on create and update
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Clicks    int64      `json:"clicks"`
+}
+
+// ClickStats is the response of stats endpoint
+type ClickStats struct {
+	ShortPath   string `json:"short_path"`
+	TotalClicks int64  `json:"total_clicks"`
+	// Maps UTC day in YYYY-MM-DD format to clicks on that day
+	Daily map[string]int64 `json:"daily"`
+}
+
+// ShortURLPage is the response of list endpoint
+type ShortURLPage struct {
+	Links []ShortURL `json:"links"`
+	// Cursor to get the next page with, absent on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// BatchResult is the result of creating an item of the batch, Status is
+// what Create would have responded with
+type BatchResult struct {
+	Status    int    `json:"status"`
+	ShortPath string `json:"short_path,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// BatchResponse is the response of batch endpoint, results are in the order
+// of the items
+type BatchResponse struct {
+	RequestID string        `json:"request_id"`
+	Results   []BatchResult `json:"results"`
 }
 
 type Response struct {
@@ -54,52 +119,28 @@ func (s *shortURLHandler) Create(w http.ResponseWriter, r *http.Request) {
 	// TODO: If request times out from client side goroutine processing the
 	// request will continue to run. Hence add timed context
 
-	// Prevent OOM/buffer overflow
-	limitReader := io.LimitReader(r.Body, maxRequestBodySize)
 	var shortURL ShortURL
-	decoder := json.NewDecoder(limitReader)
-	if err := decoder.Decode(&shortURL); err != nil {
+	if err := decodeShortURL(r, &shortURL); err != nil {
 		log.Error(err)
-		if err == io.ErrUnexpectedEOF {
-			w.WriteHeader(http.StatusRequestEntityTooLarge)
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(marshalMessage(requestID, "Request body is too large or invalid JSON"))
-			return
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(marshalMessage(requestID, "Failed to decode JSON"))
+		writeDecodeError(w, requestID, err)
 		return
 	}
-	defer r.Body.Close()
 
-	shortPath, err := s.urlShortner.CreateShortPath(r.Context(), shortURL.ShortPath, shortURL.TargetURL)
+	opts, err := createOptions(shortURL)
 	if err != nil {
 		log.Error(err)
-		switch err.(type) {
-		case *svc.ErrValidation:
-			w.WriteHeader(http.StatusBadRequest)
-		case *svc.ErrConflict:
-			w.WriteHeader(http.StatusConflict)
-		case *svc.ErrServerError:
-			// Do not expose internal error to client
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(marshalMessage(requestID, "Something went wrong"))
-			return
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(marshalMessage(requestID, "Something went wrong"))
-			return
-		}
-		w.Write(marshalMessage(requestID, err.Error()))
-		w.Header().Set("Content-Type", "application/json")
+		writeError(w, requestID, err)
+		return
+	}
+	shortPath, err := s.urlShortner.CreateShortPath(r.Context(), shortURL.ShortPath, shortURL.TargetURL, opts)
+	if err != nil {
+		log.Error(err)
+		writeError(w, requestID, err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Location", fmt.Sprintf("/%s", shortPath))
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 	w.Write(marshalMessage(requestID, fmt.Sprintf("Created short URL: /%s", shortPath)))
 	log.Infof("Sent response. shortPath:%s", shortPath)
 }
@@ -108,26 +149,334 @@ func (s *shortURLHandler) Get(w http.ResponseWriter, r *http.Request) {
 	requestID, _ := r.Context().Value(RequestIDKey("requestID")).(string)
 	log := s.log.WithField("requestID", requestID)
 	log.Infof("Received request. %s %s", r.Method, r.URL.Path)
-	// TODO: if do not redirect query param is included then return ShortURL resource
-	// otherwise redirect user to the targetURL
 	vars := mux.Vars(r)
 	shortPath := vars["id"]
-	targetURL, err := s.urlShortner.GetTargetURL(r.Context(), shortPath)
+	if !shouldRedirect(r) {
+		s.getShortURL(w, r, requestID, shortPath)
+		return
+	}
+	// Clicks are tracked under the key, which may differ in case from the
+	// shortPath requested
+	key, targetURL, err := s.urlShortner.ResolveShortPath(r.Context(), shortPath)
 	if err != nil {
 		log.Errorf("Failed to get targetURL for shortPath: %v", err)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	http.Redirect(w, r, targetURL, http.StatusMovedPermanently)
+	// Not permanent, as short paths can be retargeted, deleted or expire,
+	// and clicks must reach the server to be tracked
+	http.Redirect(w, r, targetURL, http.StatusFound)
+	s.metrics.GetCounter(metrics.RedirectsTotal).Inc()
+	s.metrics.GetClickTracker().Track(newClickEvent(r, key))
 	log.Infof("Redirected[%s] -> %s", shortPath, targetURL)
 }
 
+func (s *shortURLHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	requestID, _ := r.Context().Value(RequestIDKey("requestID")).(string)
+	log := s.log.WithField("requestID", requestID)
+	log.Infof("Received request. %s %s", r.Method, r.URL.Path)
+	vars := mux.Vars(r)
+	shortPath, _, err := s.urlShortner.ResolveShortPath(r.Context(), vars["id"])
+	if err != nil {
+		log.Error(err)
+		writeError(w, requestID, err)
+		return
+	}
+	stats, err := s.metrics.GetClickTracker().GetClickStats(r.Context(), shortPath)
+	if err != nil {
+		log.Error(err)
+		writeError(w, requestID, err)
+		return
+	}
+	dataBytes, _ := json.Marshal(ClickStats{
+		ShortPath:   shortPath,
+		TotalClicks: stats.Total,
+		Daily:       stats.Daily,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dataBytes)
+}
+
+func (s *shortURLHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	requestID, _ := r.Context().Value(RequestIDKey("requestID")).(string)
+	log := s.log.WithField("requestID", requestID)
+	log.Infof("Received request. %s %s", r.Method, r.URL.Path)
+
+	var shortURLs []ShortURL
+	defer r.Body.Close()
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBatchRequestBodySize))
+	if err := decoder.Decode(&shortURLs); err != nil {
+		log.Error(err)
+		writeDecodeError(w, requestID, err)
+		return
+	}
+	if len(shortURLs) == 0 || len(shortURLs) > maxBatchSize {
+		writeError(w, requestID, svc.NewErrValidation(fmt.Sprintf("batch must have between 1 and %d items", maxBatchSize)))
+		return
+	}
+
+	results := make([]BatchResult, len(shortURLs))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < batchConcurrency && i < len(shortURLs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = s.createBatchItem(r.Context(), log, shortURLs[i])
+			}
+		}()
+	}
+	for i := range shortURLs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	created := 0
+	for _, result := range results {
+		if result.Status == http.StatusCreated {
+			created++
+		}
+	}
+	dataBytes, _ := json.Marshal(BatchResponse{RequestID: requestID, Results: results})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dataBytes)
+	log.Infof("Sent response. created:%d failed:%d", created, len(results)-created)
+}
+
+// createBatchItem creates the ShortURL as Create does
+func (s *shortURLHandler) createBatchItem(ctx context.Context, log *logrus.Entry, shortURL ShortURL) BatchResult {
+	opts, err := createOptions(shortURL)
+	if err == nil {
+		var shortPath string
+		shortPath, err = s.urlShortner.CreateShortPath(ctx, shortURL.ShortPath, shortURL.TargetURL, opts)
+		if err == nil {
+			return BatchResult{Status: http.StatusCreated, ShortPath: shortPath}
+		}
+	}
+	log.Error(err)
+	status, msg := errorResponse(err)
+	return BatchResult{Status: status, Message: msg}
+}
+
+// newClickEvent builds ClickEvent from the redirect request
+func newClickEvent(r *http.Request, shortPath string) metrics.ClickEvent {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	remoteIPHash := sha256.Sum256([]byte(remoteIP))
+	return metrics.ClickEvent{
+		ShortPath:    shortPath,
+		Timestamp:    time.Now(),
+		Referrer:     r.Referer(),
+		UserAgent:    r.UserAgent(),
+		RemoteIPHash: hex.EncodeToString(remoteIPHash[:]),
+	}
+}
+
+// getShortURL writes the ShortURL resource instead of redirecting
+func (s *shortURLHandler) getShortURL(w http.ResponseWriter, r *http.Request, requestID string, shortPath string) {
+	log := s.log.WithField("requestID", requestID)
+	shortURL, err := s.urlShortner.GetShortURL(r.Context(), shortPath)
+	if err != nil {
+		log.Error(err)
+		writeError(w, requestID, err)
+		return
+	}
+	dataBytes, _ := json.Marshal(newShortURLResource(shortURL))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dataBytes)
+	log.Infof("Sent response. shortPath:%s", shortPath)
+}
+
+func (s *shortURLHandler) List(w http.ResponseWriter, r *http.Request) {
+	requestID, _ := r.Context().Value(RequestIDKey("requestID")).(string)
+	log := s.log.WithField("requestID", requestID)
+	log.Infof("Received request. %s %s", r.Method, r.URL.Path)
+	query := r.URL.Query()
+	opts := svc.ListOptions{
+		Cursor: query.Get("cursor"),
+		Limit:  defaultListLimit,
+		Query:  query.Get("q"),
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			writeError(w, requestID, svc.NewErrValidation(fmt.Sprintf("limit must be between 1 and %d", maxListLimit)))
+			return
+		}
+		opts.Limit = limit
+	}
+	page, err := s.urlShortner.ListShortURLs(r.Context(), opts)
+	if err != nil {
+		log.Error(err)
+		writeError(w, requestID, err)
+		return
+	}
+	resource := ShortURLPage{
+		Links:      make([]ShortURL, 0, len(page.ShortURLs)),
+		NextCursor: page.NextCursor,
+	}
+	for i := range page.ShortURLs {
+		resource.Links = append(resource.Links, newShortURLResource(&page.ShortURLs[i]))
+	}
+	dataBytes, _ := json.Marshal(resource)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dataBytes)
+	log.Infof("Sent response. links:%d", len(resource.Links))
+}
+
+// newShortURLResource converts svc.ShortURL to the ShortURL resource
+func newShortURLResource(shortURL *svc.ShortURL) ShortURL {
+	resource := ShortURL{
+		ShortPath: shortURL.ShortPath,
+		TargetURL: shortURL.TargetURL,
+		Tags:      shortURL.Tags,
+		Clicks:    shortURL.Clicks,
+	}
+	if !shortURL.CreatedAt.IsZero() {
+		resource.CreatedAt = &shortURL.CreatedAt
+	}
+	if !shortURL.ExpiresAt.IsZero() {
+		resource.ExpiresAt = &shortURL.ExpiresAt
+	}
+	return resource
+}
+
+// shouldRedirect returns false if client asked for the ShortURL resource,
+// either with redirect=false query param or by accepting JSON
+func shouldRedirect(r *http.Request) bool {
+	if redirect, err := strconv.ParseBool(r.URL.Query().Get("redirect")); err == nil {
+		return redirect
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			if strings.TrimSpace(mediaType) == "application/json" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (s *shortURLHandler) Put(w http.ResponseWriter, r *http.Request) {
-	//TODO: Implement
+	requestID, _ := r.Context().Value(RequestIDKey("requestID")).(string)
+	log := s.log.WithField("requestID", requestID)
+	log.Infof("Received request. %s %s", r.Method, r.URL.Path)
+	vars := mux.Vars(r)
+	shortPath := vars["id"]
+
+	var shortURL ShortURL
+	if err := decodeShortURL(r, &shortURL); err != nil {
+		log.Error(err)
+		writeDecodeError(w, requestID, err)
+		return
+	}
+	if shortURL.ShortPath != "" && shortURL.ShortPath != shortPath {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(marshalMessage(requestID, "short_path in body does not match the path"))
+		return
+	}
+
+	err := s.urlShortner.UpdateTargetURL(r.Context(), shortPath, shortURL.TargetURL)
+	if err != nil {
+		log.Error(err)
+		writeError(w, requestID, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshalMessage(requestID, fmt.Sprintf("Updated short URL: /%s", shortPath)))
+	log.Infof("Sent response. shortPath:%s targetURL:%s", shortPath, shortURL.TargetURL)
 }
 
 func (s *shortURLHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	//TODO: Implement
+	requestID, _ := r.Context().Value(RequestIDKey("requestID")).(string)
+	log := s.log.WithField("requestID", requestID)
+	log.Infof("Received request. %s %s", r.Method, r.URL.Path)
+	vars := mux.Vars(r)
+	shortPath := vars["id"]
+	err := s.urlShortner.DeleteShortPath(r.Context(), shortPath)
+	if err != nil {
+		log.Error(err)
+		writeError(w, requestID, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	log.Infof("Deleted shortPath:%s", shortPath)
+}
+
+// createOptions converts optional attributes of ShortURL to svc.CreateOptions
+func createOptions(shortURL ShortURL) (svc.CreateOptions, error) {
+	opts := svc.CreateOptions{
+		Tags:  shortURL.Tags,
+		Style: shortURL.Style,
+	}
+	if shortURL.ExpiresAt != nil && shortURL.TTLSeconds != 0 {
+		return opts, svc.NewErrValidation("only one of expires_at and ttl_seconds can be provided")
+	}
+	if shortURL.TTLSeconds < 0 {
+		return opts, svc.NewErrValidation("ttl_seconds must be positive")
+	}
+	if shortURL.ExpiresAt != nil {
+		opts.ExpiresAt = *shortURL.ExpiresAt
+	}
+	if shortURL.TTLSeconds > 0 {
+		opts.ExpiresAt = time.Now().Add(time.Duration(shortURL.TTLSeconds) * time.Second)
+	}
+	return opts, nil
+}
+
+// decodeShortURL reads the ShortURL JSON from request body
+func decodeShortURL(r *http.Request, shortURL *ShortURL) error {
+	defer r.Body.Close()
+	// Prevent OOM/buffer overflow
+	limitReader := io.LimitReader(r.Body, maxRequestBodySize)
+	decoder := json.NewDecoder(limitReader)
+	return decoder.Decode(shortURL)
+}
+
+func writeDecodeError(w http.ResponseWriter, requestID string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err == io.ErrUnexpectedEOF {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(marshalMessage(requestID, "Request body is too large or invalid JSON"))
+		return
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(marshalMessage(requestID, "Failed to decode JSON"))
+}
+
+// writeError maps errors returned by svc package to HTTP status codes
+func writeError(w http.ResponseWriter, requestID string, err error) {
+	status, msg := errorResponse(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(marshalMessage(requestID, msg))
+}
+
+// errorResponse returns HTTP status code and message of error returned by
+// svc package
+func errorResponse(err error) (int, string) {
+	switch err.(type) {
+	case *svc.ErrValidation:
+		return http.StatusBadRequest, err.Error()
+	case *svc.ErrConflict:
+		return http.StatusConflict, err.Error()
+	case *svc.ErrNotFound:
+		return http.StatusNotFound, err.Error()
+	default:
+		// Do not expose internal error to client
+		return http.StatusInternalServerError, "Something went wrong"
+	}
 }
 
 func marshalMessage(requestID string, msg string) []byte {
@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/thenilesh/url-shortner/metrics"
 	"github.com/thenilesh/url-shortner/mocks"
+	"github.com/thenilesh/url-shortner/mocks/svcmocks"
 	"github.com/thenilesh/url-shortner/rest"
 	"github.com/thenilesh/url-shortner/svc"
 )
@@ -21,8 +25,9 @@ func TestShortURLHandler_Create_Success(t *testing.T) {
 	log := logrus.New()
 	log.SetLevel(logrus.ErrorLevel)
 
-	mockSvc := new(mocks.URLShortner)
-	handler := rest.NewShortURLHandler(log, mockSvc)
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
 
 	router := mux.NewRouter()
 	router.HandleFunc("/shorturl", handler.Create).Methods(http.MethodPost)
@@ -35,7 +40,7 @@ func TestShortURLHandler_Create_Success(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	expectedShortPath := "test"
-	mockSvc.On("CreateShortPath", mock.Anything, shortURL.ShortPath, shortURL.TargetURL).Return(expectedShortPath, nil)
+	mockSvc.On("CreateShortPath", mock.Anything, shortURL.ShortPath, shortURL.TargetURL, svc.CreateOptions{}).Return(expectedShortPath, nil)
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -52,8 +57,9 @@ func TestShortURLHandler_Create_BadRequest(t *testing.T) {
 	log := logrus.New()
 	log.SetLevel(logrus.ErrorLevel)
 
-	mockSvc := new(mocks.URLShortner)
-	handler := rest.NewShortURLHandler(log, mockSvc)
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
 
 	router := mux.NewRouter()
 	router.HandleFunc("/shorturl", handler.Create).Methods(http.MethodPost)
@@ -65,7 +71,7 @@ func TestShortURLHandler_Create_BadRequest(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodPost, "/shorturl", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 
-	mockSvc.On("CreateShortPath", mock.Anything, shortURL.ShortPath, shortURL.TargetURL).Return("", &svc.ErrValidation{})
+	mockSvc.On("CreateShortPath", mock.Anything, shortURL.ShortPath, shortURL.TargetURL, svc.CreateOptions{}).Return("", &svc.ErrValidation{})
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -77,8 +83,9 @@ func TestShortURLHandler_Create_conflict(t *testing.T) {
 	log := logrus.New()
 	log.SetLevel(logrus.ErrorLevel)
 
-	mockSvc := new(mocks.URLShortner)
-	handler := rest.NewShortURLHandler(log, mockSvc)
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
 
 	router := mux.NewRouter()
 	router.HandleFunc("/shorturl", handler.Create).Methods(http.MethodPost)
@@ -90,10 +97,381 @@ func TestShortURLHandler_Create_conflict(t *testing.T) {
 	req, _ := http.NewRequest(http.MethodPost, "/shorturl", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 
-	mockSvc.On("CreateShortPath", mock.Anything, shortURL.ShortPath, shortURL.TargetURL).Return("", &svc.ErrConflict{})
+	mockSvc.On("CreateShortPath", mock.Anything, shortURL.ShortPath, shortURL.TargetURL, svc.CreateOptions{}).Return("", &svc.ErrConflict{})
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusConflict, rr.Code)
 }
+
+func TestShortURLHandler_Put(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.Put).Methods(http.MethodPut)
+
+	tests := []struct {
+		name       string
+		shortPath  string
+		body       rest.ShortURL
+		svcErr     error
+		expectCall bool
+		statusCode int
+	}{
+		{"updated", "test", rest.ShortURL{TargetURL: "http://example.com"}, nil, true, http.StatusOK},
+		{"not found", "missing", rest.ShortURL{TargetURL: "http://example.com"}, &svc.ErrNotFound{}, true, http.StatusNotFound},
+		{"invalid", "test", rest.ShortURL{TargetURL: "ftp://example.com"}, &svc.ErrValidation{}, true, http.StatusBadRequest},
+		{"conflict", "test", rest.ShortURL{TargetURL: "http://example.org"}, &svc.ErrConflict{}, true, http.StatusConflict},
+		{"path mismatch", "test", rest.ShortURL{ShortPath: "other", TargetURL: "http://example.com"}, nil, false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expectCall {
+				mockSvc.On("UpdateTargetURL", mock.Anything, tt.shortPath, tt.body.TargetURL).Return(tt.svcErr).Once()
+			}
+			body, _ := json.Marshal(tt.body)
+			req, _ := http.NewRequest(http.MethodPut, "/"+tt.shortPath, bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.statusCode, rr.Code)
+		})
+	}
+	mockSvc.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Delete(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.Delete).Methods(http.MethodDelete)
+
+	mockSvc.On("DeleteShortPath", mock.Anything, "test").Return(nil)
+	mockSvc.On("DeleteShortPath", mock.Anything, "missing").Return(&svc.ErrNotFound{})
+
+	req, _ := http.NewRequest(http.MethodDelete, "/test", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	req, _ = http.NewRequest(http.MethodDelete, "/missing", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockSvc.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Create_expiry(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/shorturl", handler.Create).Methods(http.MethodPost)
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockSvc.On("CreateShortPath", mock.Anything, "fixed", "http://example.com", svc.CreateOptions{ExpiresAt: expiresAt}).Return("fixed", nil)
+	mockSvc.On("CreateShortPath", mock.Anything, "relative", "http://example.com", mock.MatchedBy(func(opts svc.CreateOptions) bool {
+		ttl := time.Until(opts.ExpiresAt)
+		return ttl > 59*time.Second && ttl <= time.Minute
+	})).Return("relative", nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+	}{
+		{"expires_at", `{"short_path":"fixed","target_url":"http://example.com","expires_at":"2030-01-01T00:00:00Z"}`, http.StatusCreated},
+		{"ttl_seconds", `{"short_path":"relative","target_url":"http://example.com","ttl_seconds":60}`, http.StatusCreated},
+		{"both", `{"target_url":"http://example.com","ttl_seconds":60,"expires_at":"2030-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"negative ttl", `{"target_url":"http://example.com","ttl_seconds":-1}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/shorturl", bytes.NewReader([]byte(tt.body)))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.statusCode, rr.Code)
+		})
+	}
+	mockSvc.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Get(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.Get).Methods(http.MethodGet)
+
+	mockSvc.On("ResolveShortPath", mock.Anything, "test").Return("test", "http://example.com", nil)
+	mockSvc.On("ResolveShortPath", mock.Anything, "missing").Return("", "", &svc.ErrNotFound{})
+	mockSvc.On("GetShortURL", mock.Anything, "test").Return(&svc.ShortURL{
+		ShortPath: "test",
+		TargetURL: "http://example.com",
+		CreatedAt: time.Date(2023, 10, 1, 10, 0, 0, 0, time.UTC),
+		Clicks:    7,
+	}, nil)
+	mockSvc.On("GetShortURL", mock.Anything, "missing").Return(nil, &svc.ErrNotFound{})
+	mockClickTracker := new(mocks.ClickTracker)
+	mockClickTracker.On("Track", mock.MatchedBy(func(event metrics.ClickEvent) bool {
+		return event.ShortPath == "test" && event.Referrer == "http://referrer.com" && len(event.RemoteIPHash) == 64
+	})).Once()
+	mockMetrics.On("GetClickTracker").Return(mockClickTracker)
+	mockMetrics.On("GetCounter", metrics.RedirectsTotal).Return(metrics.NewCounterVec(metrics.RedirectsTotal, ""))
+
+	req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Referer", "http://referrer.com")
+	req.RemoteAddr = "192.0.2.1:1234"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "http://example.com", rr.Header().Get("Location"))
+
+	req, _ = http.NewRequest(http.MethodGet, "/missing", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	expectedBody := `{"short_path":"test","target_url":"http://example.com","created_at":"2023-10-01T10:00:00Z","clicks":7}`
+	req, _ = http.NewRequest(http.MethodGet, "/test?redirect=false", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, expectedBody, rr.Body.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Accept", "text/plain, application/json;q=0.9")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, expectedBody, rr.Body.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "/missing?redirect=false", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockSvc.AssertExpectations(t)
+	mockClickTracker.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Get_key(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.Get).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/stats", handler.Stats).Methods(http.MethodGet)
+
+	// PROMO is stored as promo in case-insensitive mode
+	mockSvc.On("ResolveShortPath", mock.Anything, "PROMO").Return("promo", "http://example.com", nil)
+	mockClickTracker := new(mocks.ClickTracker)
+	mockClickTracker.On("Track", mock.MatchedBy(func(event metrics.ClickEvent) bool {
+		return event.ShortPath == "promo"
+	})).Once()
+	mockClickTracker.On("GetClickStats", mock.Anything, "promo").Return(metrics.ClickStats{Total: 1, Daily: map[string]int64{}}, nil)
+	mockMetrics.On("GetClickTracker").Return(mockClickTracker)
+	mockMetrics.On("GetCounter", metrics.RedirectsTotal).Return(metrics.NewCounterVec(metrics.RedirectsTotal, ""))
+
+	req, _ := http.NewRequest(http.MethodGet, "/PROMO", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, "http://example.com", rr.Header().Get("Location"))
+
+	req, _ = http.NewRequest(http.MethodGet, "/PROMO/stats", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"short_path":"promo","total_clicks":1,"daily":{}}`, rr.Body.String())
+
+	mockSvc.AssertExpectations(t)
+	mockClickTracker.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Stats(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	mockClickTracker := new(mocks.ClickTracker)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}/stats", handler.Stats).Methods(http.MethodGet)
+
+	mockSvc.On("ResolveShortPath", mock.Anything, "test").Return("test", "http://example.com", nil)
+	mockSvc.On("ResolveShortPath", mock.Anything, "missing").Return("", "", &svc.ErrNotFound{})
+	mockMetrics.On("GetClickTracker").Return(mockClickTracker)
+	mockClickTracker.On("GetClickStats", mock.Anything, "test").Return(metrics.ClickStats{
+		Total: 3,
+		Daily: map[string]int64{"2023-10-01": 1, "2023-10-02": 2},
+	}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/test/stats", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"short_path":"test","total_clicks":3,"daily":{"2023-10-01":1,"2023-10-02":2}}`, rr.Body.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "/missing/stats", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	mockSvc.AssertExpectations(t)
+	mockClickTracker.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Create_style(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/shorturl", handler.Create).Methods(http.MethodPost)
+	body := `{"target_url": "http://example.com", "style": "words"}`
+	req, _ := http.NewRequest(http.MethodPost, "/shorturl", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	mockSvc.On("CreateShortPath", mock.Anything, "", "http://example.com", svc.CreateOptions{Style: svc.StyleWords}).Return("brave-otter-42", nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "/brave-otter-42", rr.Header().Get("Location"))
+	mockSvc.AssertExpectations(t)
+}
+
+func TestShortURLHandler_List(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/links", handler.List).Methods(http.MethodGet)
+
+	createdAt := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	mockSvc.On("ListShortURLs", mock.Anything, svc.ListOptions{Cursor: "", Limit: 20}).Return(&svc.ShortURLPage{
+		ShortURLs: []svc.ShortURL{
+			{ShortPath: "docs", TargetURL: "https://docs.example.com", CreatedAt: createdAt, Clicks: 2},
+		},
+		NextCursor: "docs",
+	}, nil)
+	mockSvc.On("ListShortURLs", mock.Anything, svc.ListOptions{Cursor: "docs", Limit: 5, Query: "example"}).Return(&svc.ShortURLPage{
+		ShortURLs: []svc.ShortURL{},
+	}, nil)
+	mockSvc.On("ListShortURLs", mock.Anything, svc.ListOptions{Cursor: "bad", Limit: 20}).Return(nil, svc.NewErrValidation("invalid cursor"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/links", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"links":[{"short_path":"docs","target_url":"https://docs.example.com","created_at":"2023-10-01T00:00:00Z","clicks":2}],"next_cursor":"docs"}`, rr.Body.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/links?cursor=docs&limit=5&q=example", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"links":[]}`, rr.Body.String())
+
+	for _, url := range []string{"/api/links?limit=0", "/api/links?limit=101", "/api/links?limit=x", "/api/links?cursor=bad"} {
+		req, _ = http.NewRequest(http.MethodGet, url, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, url)
+	}
+	mockSvc.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Batch(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+
+	mockSvc := new(svcmocks.URLShortner)
+	mockMetrics := new(mocks.Metrics)
+	handler := rest.NewShortURLHandler(log, mockSvc, mockMetrics)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/links:batch", handler.Batch).Methods(http.MethodPost)
+
+	mockSvc.On("CreateShortPath", mock.Anything, "docs", "http://example.com/docs", svc.CreateOptions{}).Return("docs", nil)
+	mockSvc.On("CreateShortPath", mock.Anything, "", "http://example.com/blog", svc.CreateOptions{}).Return("abc123", nil)
+	mockSvc.On("CreateShortPath", mock.Anything, "taken", "http://example.com/other", svc.CreateOptions{}).Return("", svc.NewErrConflict("shortpath already exists for different targetURL"))
+	mockSvc.On("CreateShortPath", mock.Anything, "", "http://example.com/down", svc.CreateOptions{}).Return("", svc.NewErrServerError("could not save shortpath", nil))
+
+	body := `[
+		{"short_path": "docs", "target_url": "http://example.com/docs"},
+		{"target_url": "http://example.com/blog"},
+		{"short_path": "taken", "target_url": "http://example.com/other"},
+		{"target_url": "http://example.com/down"},
+		{"target_url": "http://example.com/ttl", "ttl_seconds": -1}
+	]`
+	req, _ := http.NewRequest(http.MethodPost, "/api/links:batch", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp rest.BatchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, []rest.BatchResult{
+		{Status: http.StatusCreated, ShortPath: "docs"},
+		{Status: http.StatusCreated, ShortPath: "abc123"},
+		{Status: http.StatusConflict, Message: "shortpath already exists for different targetURL"},
+		{Status: http.StatusInternalServerError, Message: "Something went wrong"},
+		{Status: http.StatusBadRequest, Message: "ttl_seconds must be positive"},
+	}, resp.Results)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestShortURLHandler_Batch_invalid(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.FatalLevel)
+
+	handler := rest.NewShortURLHandler(log, new(svcmocks.URLShortner), new(mocks.Metrics))
+	router := mux.NewRouter()
+	router.HandleFunc("/api/links:batch", handler.Batch).Methods(http.MethodPost)
+
+	items := make([]string, 1001)
+	for i := range items {
+		items[i] = `{"target_url": "http://example.com"}`
+	}
+	for _, body := range []string{`[]`, `{"target_url": "http://example.com"}`, "[" + strings.Join(items, ",") + "]"} {
+		req, _ := http.NewRequest(http.MethodPost, "/api/links:batch", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func NewGoMapCounterStore() CounterStore {
+	return &goMapCounterStore{
+		counters: make(map[string]map[string]int64),
+	}
+}
+
+// goMapCounterStore is an in-memory CounterStore safe for concurrent use
+type goMapCounterStore struct {
+	mu       sync.RWMutex
+	counters map[string]map[string]int64
+}
+
+func (c *goMapCounterStore) Incr(_ context.Context, key string, field string, delta int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fields, ok := c.counters[key]
+	if !ok {
+		fields = make(map[string]int64)
+		c.counters[key] = fields
+	}
+	fields[field] += delta
+	return nil
+}
+
+func (c *goMapCounterStore) GetAll(_ context.Context, key string) (map[string]int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	all := make(map[string]int64, len(c.counters[key]))
+	for field, value := range c.counters[key] {
+		all[field] = value
+	}
+	return all, nil
+}
+
+func (c *goMapCounterStore) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counters, key)
+	return nil
+}
+
+// redisCounterStore keeps counters of a key as fields of a redis hash
+type redisCounterStore struct {
+	client    *redis.Client
+	namespace string
+}
+
+func NewRedisCounterStore(client *redis.Client, namespace string) (*redisCounterStore, error) {
+	return &redisCounterStore{
+		client:    client,
+		namespace: namespace,
+	}, nil
+}
+
+func (store *redisCounterStore) Incr(ctx context.Context, key string, field string, delta int64) error {
+	return store.client.HIncrBy(ctx, store.namespacedKey(key), field, delta).Err()
+}
+
+func (store *redisCounterStore) GetAll(ctx context.Context, key string) (map[string]int64, error) {
+	values, err := store.client.HGetAll(ctx, store.namespacedKey(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]int64, len(values))
+	for field, value := range values {
+		all[field], err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("counter %s of %s is not an integer: %w", field, key, err)
+		}
+	}
+	return all, nil
+}
+
+func (store *redisCounterStore) Delete(ctx context.Context, key string) error {
+	return store.client.Del(ctx, store.namespacedKey(key)).Err()
+}
+
+func (store *redisCounterStore) namespacedKey(key string) string {
+	return fmt.Sprintf("%s:%s", store.namespace, key)
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, _ := NewRedisClient(mr.Addr(), "", 0)
+	redisCounterStore, _ := NewRedisCounterStore(client, "clicks")
+	stores := map[string]CounterStore{
+		"gomap": NewGoMapCounterStore(),
+		"redis": redisCounterStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			assert.NoError(t, store.Incr(ctx, "abc", "total", 1))
+			assert.NoError(t, store.Incr(ctx, "abc", "total", 2))
+			assert.NoError(t, store.Incr(ctx, "abc", "2023-10-01", 1))
+
+			all, err := store.GetAll(ctx, "abc")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]int64{"total": 3, "2023-10-01": 1}, all)
+
+			all, err = store.GetAll(ctx, "unknown")
+			assert.NoError(t, err)
+			assert.Empty(t, all)
+
+			assert.NoError(t, store.Incr(ctx, "xyz", "total", 1))
+			assert.NoError(t, store.Delete(ctx, "xyz"))
+			all, err = store.GetAll(ctx, "xyz")
+			assert.NoError(t, err)
+			assert.Empty(t, all)
+			assert.NoError(t, store.Delete(ctx, "unknown"))
+		})
+	}
+	assert.Equal(t, "3", mr.HGet("clicks:abc", "total"))
+	assert.False(t, mr.Exists("clicks:xyz"))
+}
+
+func TestRankStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, _ := NewRedisClient(mr.Addr(), "", 0)
+	redisRankStore, _ := NewRedisRankStore(client, "collectors")
+	stores := map[string]RankStore{
+		"gomap": NewGoMapRankStore(),
+		"redis": redisRankStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			assert.NoError(t, store.IncrScore(ctx, "domains", "a.com", 1))
+			assert.NoError(t, store.IncrScore(ctx, "domains", "b.com", 3))
+			assert.NoError(t, store.IncrScore(ctx, "domains", "a.com", 1))
+			assert.NoError(t, store.IncrScore(ctx, "domains", "c.com", 2))
+			assert.NoError(t, store.IncrScore(ctx, "domains", "d.com", 1))
+
+			top, err := store.TopN(ctx, "domains", 3)
+			assert.NoError(t, err)
+			assert.Equal(t, []RankedMember{{"b.com", 3}, {"c.com", 2}, {"a.com", 2}}, top)
+
+			top, err = store.TopN(ctx, "domains", 10)
+			assert.NoError(t, err)
+			assert.Len(t, top, 4)
+
+			top, err = store.TopN(ctx, "unknown", 3)
+			assert.NoError(t, err)
+			assert.Empty(t, top)
+		})
+	}
+	score, err := mr.ZScore("collectors:domains", "b.com")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), score)
+}
@@ -1,37 +1,144 @@
 package store
 
-import "context"
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
 
 func NewGoMapStore() KVStore {
-	return &goMapStore{}
+	return &goMapStore{
+		kv:  make(map[string]goMapEntry),
+		now: time.Now,
+	}
+}
+
+type goMapEntry struct {
+	value string
+	// zero value means the entry never expires
+	expiresAt time.Time
 }
 
-type goMapStore map[string]string
+func (e goMapEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// goMapStore is an in-memory KVStore safe for concurrent use.
+// Expired entries are evicted lazily when they are accessed.
+type goMapStore struct {
+	mu  sync.RWMutex
+	kv  map[string]goMapEntry
+	now func() time.Time
+}
 
 func (k *goMapStore) Put(_ context.Context, key string, value string) error {
-	kv := *k
-	kv[key] = value
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entry, ok := k.lookup(key)
+	if !ok {
+		entry = goMapEntry{}
+	}
+	entry.value = value
+	k.kv[key] = entry
 	return nil
 }
 
+func (k *goMapStore) PutIfAbsent(_ context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.lookup(key); ok {
+		return false, nil
+	}
+	entry := goMapEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = k.now().Add(ttl)
+	}
+	k.kv[key] = entry
+	return true, nil
+}
+
+func (k *goMapStore) ReplaceIf(_ context.Context, key string, old string, value string, ttl time.Duration) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if entry, ok := k.lookup(key); !ok || entry.value != old {
+		return false, nil
+	}
+	entry := goMapEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = k.now().Add(ttl)
+	}
+	k.kv[key] = entry
+	return true, nil
+}
+
 func (k *goMapStore) Get(_ context.Context, key string) (string, error) {
-	kv := *k
-	if val, ok := kv[key]; ok {
-		return val, nil
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if entry, ok := k.lookup(key); ok {
+		return entry.value, nil
 	}
 	return "", ErrKeyNotFound
 }
 
 func (k *goMapStore) Exists(_ context.Context, key string) (bool, error) {
-	kv := *k
-	if _, ok := kv[key]; ok {
-		return true, nil
-	}
-	return false, nil
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	_, ok := k.lookup(key)
+	return ok, nil
 }
 
 func (k *goMapStore) Delete(_ context.Context, key string) error {
-	kv := *k
-	delete(kv, key)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.kv, key)
 	return nil
 }
+
+func (k *goMapStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entry, ok := k.lookup(key)
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	if entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(k.now()), nil
+}
+
+// Scan returns the keys in sorted order, the cursor is the last key
+// returned. Every page sorts all the keys, which is fine for the sizes this
+// store is meant for.
+func (k *goMapStore) Scan(_ context.Context, cursor string, count int) ([]string, string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := k.now()
+	keys := make([]string, 0)
+	for key, entry := range k.kv {
+		if key > cursor && !entry.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) <= count {
+		return keys, "", nil
+	}
+	keys = keys[:count]
+	return keys, keys[count-1], nil
+}
+
+// lookup returns the entry if it exists and has not expired.
+// It evicts the expired entry, hence caller must hold the write lock.
+func (k *goMapStore) lookup(key string) (goMapEntry, bool) {
+	entry, ok := k.kv[key]
+	if !ok {
+		return goMapEntry{}, false
+	}
+	if entry.expired(k.now()) {
+		delete(k.kv, key)
+		return goMapEntry{}, false
+	}
+	return entry, true
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -57,3 +58,110 @@ func TestGoMapStore(t *testing.T) {
 		t.Errorf("Expected key1 to not exist")
 	}
 }
+
+func TestGoMapStore_PutIfAbsent(t *testing.T) {
+	store := NewGoMapStore()
+
+	stored, err := store.PutIfAbsent(context.Background(), "key1", "value1", 0)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+
+	stored, err = store.PutIfAbsent(context.Background(), "key1", "value2", 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+
+	val, err := store.Get(context.Background(), "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+}
+
+func TestGoMapStore_ReplaceIf(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewGoMapStore()
+	store.(*goMapStore).now = func() time.Time { return now }
+
+	replaced, err := store.ReplaceIf(ctx, "key1", "", "value1", 0)
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+	_, err = store.Get(ctx, "key1")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	assert.NoError(t, store.Put(ctx, "key1", "value1"))
+	replaced, err = store.ReplaceIf(ctx, "key1", "other", "value2", 0)
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+	replaced, err = store.ReplaceIf(ctx, "key1", "value1", "value2", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+
+	val, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", val)
+	ttl, err := store.TTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestGoMapStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewGoMapStore()
+	store.(*goMapStore).now = func() time.Time { return now }
+
+	_, err := store.PutIfAbsent(ctx, "temp", "value1", time.Minute)
+	assert.NoError(t, err)
+	_, err = store.PutIfAbsent(ctx, "perm", "value2", 0)
+	assert.NoError(t, err)
+
+	ttl, err := store.TTL(ctx, "temp")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	ttl, err = store.TTL(ctx, "perm")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	_, err = store.TTL(ctx, "notfoundkey")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// Put keeps the expiry
+	err = store.Put(ctx, "temp", "value3")
+	assert.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	_, err = store.Get(ctx, "temp")
+	assert.Equal(t, ErrKeyNotFound, err)
+	exists, err := store.Exists(ctx, "temp")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	stored, err := store.PutIfAbsent(ctx, "temp", "value4", 0)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+
+	val, err := store.Get(ctx, "perm")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", val)
+}
+
+func TestGoMapStore_Scan(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := &goMapStore{kv: make(map[string]goMapEntry), now: func() time.Time { return now }}
+	for _, key := range []string{"d", "b", "e", "a", "c"} {
+		assert.NoError(t, store.Put(ctx, key, "value"))
+	}
+	_, err := store.PutIfAbsent(ctx, "bb", "value", time.Second)
+	assert.NoError(t, err)
+	now = now.Add(time.Second)
+
+	keys, cursor, err := store.Scan(ctx, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+	// Expired bb is skipped
+	keys, cursor, err = store.Scan(ctx, cursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, keys)
+	keys, cursor, err = store.Scan(ctx, cursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e"}, keys)
+	assert.Equal(t, "", cursor)
+}
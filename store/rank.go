@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func NewGoMapRankStore() RankStore {
+	return &goMapRankStore{
+		scores: make(map[string]map[string]int64),
+	}
+}
+
+// goMapRankStore is an in-memory RankStore safe for concurrent use
+type goMapRankStore struct {
+	mu     sync.RWMutex
+	scores map[string]map[string]int64
+}
+
+func (r *goMapRankStore) IncrScore(_ context.Context, key string, member string, delta int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	members, ok := r.scores[key]
+	if !ok {
+		members = make(map[string]int64)
+		r.scores[key] = members
+	}
+	members[member] += delta
+	return nil
+}
+
+func (r *goMapRankStore) TopN(_ context.Context, key string, n int) ([]RankedMember, error) {
+	r.mu.RLock()
+	ranked := make([]RankedMember, 0, len(r.scores[key]))
+	for member, score := range r.scores[key] {
+		ranked = append(ranked, RankedMember{Member: member, Score: score})
+	}
+	r.mu.RUnlock()
+	// Ties are ordered like redis does, in reverse lexicographical order
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Member > ranked[j].Member
+	})
+	if n < len(ranked) {
+		ranked = ranked[:n]
+	}
+	return ranked, nil
+}
+
+// redisRankStore keeps members of a key in a redis sorted set, so that
+// scores are shared by every instance using the same redis
+type redisRankStore struct {
+	client    *redis.Client
+	namespace string
+}
+
+func NewRedisRankStore(client *redis.Client, namespace string) (*redisRankStore, error) {
+	return &redisRankStore{
+		client:    client,
+		namespace: namespace,
+	}, nil
+}
+
+func (store *redisRankStore) IncrScore(ctx context.Context, key string, member string, delta int64) error {
+	return store.client.ZIncrBy(ctx, store.namespacedKey(key), float64(delta), member).Err()
+}
+
+func (store *redisRankStore) TopN(ctx context.Context, key string, n int) ([]RankedMember, error) {
+	if n <= 0 {
+		return []RankedMember{}, nil
+	}
+	values, err := store.client.ZRevRangeWithScores(ctx, store.namespacedKey(key), 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	ranked := make([]RankedMember, 0, len(values))
+	for _, z := range values {
+		member, ok := z.Member.(string)
+		if !ok {
+			return nil, fmt.Errorf("member %v of %s is not a string", z.Member, key)
+		}
+		ranked = append(ranked, RankedMember{Member: member, Score: int64(z.Score)})
+	}
+	return ranked, nil
+}
+
+func (store *redisRankStore) namespacedKey(key string) string {
+	return fmt.Sprintf("%s:%s", store.namespace, key)
+}
@@ -3,6 +3,9 @@ package store
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -34,11 +37,39 @@ func NewRedisKVStore(client *redis.Client, namespace string) (*redisKVStore, err
 }
 
 func (store *redisKVStore) Put(ctx context.Context, key string, value string) error {
-	return store.client.Set(ctx, fmt.Sprintf("%s:%s", store.namespace, key), value, 0).Err()
+	return store.client.Set(ctx, store.namespacedKey(key), value, redis.KeepTTL).Err()
+}
+
+// PutIfAbsent uses SETNX so that concurrent writers can not overwrite each other
+func (store *redisKVStore) PutIfAbsent(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return store.client.SetNX(ctx, store.namespacedKey(key), value, ttl).Result()
+}
+
+// replaceIfScript sets KEYS[1] to ARGV[2] only if it holds ARGV[1], ARGV[3]
+// is the expiry in milliseconds, zero for none
+var replaceIfScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// ReplaceIf runs a script so that the comparison and the write are atomic
+func (store *redisKVStore) ReplaceIf(ctx context.Context, key string, old string, value string, ttl time.Duration) (bool, error) {
+	replaced, err := replaceIfScript.Run(ctx, store.client, []string{store.namespacedKey(key)}, old, value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return replaced == 1, nil
 }
 
 func (store *redisKVStore) Get(ctx context.Context, key string) (string, error) {
-	val, err := store.client.Get(ctx, fmt.Sprintf("%s:%s", store.namespace, key)).Result()
+	val, err := store.client.Get(ctx, store.namespacedKey(key)).Result()
 	if err == redis.Nil {
 		return "", ErrKeyNotFound
 	}
@@ -46,7 +77,7 @@ func (store *redisKVStore) Get(ctx context.Context, key string) (string, error)
 }
 
 func (store *redisKVStore) Exists(ctx context.Context, key string) (bool, error) {
-	exists, err := store.client.Exists(ctx, key).Result()
+	exists, err := store.client.Exists(ctx, store.namespacedKey(key)).Result()
 	if err != nil {
 		return false, err
 	}
@@ -54,5 +85,50 @@ func (store *redisKVStore) Exists(ctx context.Context, key string) (bool, error)
 }
 
 func (store *redisKVStore) Delete(ctx context.Context, key string) error {
-	return store.client.Del(ctx, fmt.Sprintf("%s:%s", store.namespace, key)).Err()
+	return store.client.Del(ctx, store.namespacedKey(key)).Err()
+}
+
+func (store *redisKVStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := store.client.PTTL(ctx, store.namespacedKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL replies -2 if the key does not exist and -1 if it has no expiry
+	if ttl == -2 {
+		return 0, ErrKeyNotFound
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Scan uses SCAN over the keys of the namespace, the cursor is the one
+// returned by redis. As with SCAN, a page may have more or less keys than
+// count, even none before the iteration is complete.
+func (store *redisKVStore) Scan(ctx context.Context, cursor string, count int) ([]string, string, error) {
+	var redisCursor uint64
+	if cursor != "" {
+		var err error
+		redisCursor, err = strconv.ParseUint(cursor, 10, 64)
+		if err != nil || redisCursor == 0 {
+			return nil, "", ErrInvalidCursor
+		}
+	}
+	keys, redisCursor, err := store.client.Scan(ctx, redisCursor, store.namespacedKey("*"), int64(count)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	prefix := store.namespacedKey("")
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, prefix)
+	}
+	if redisCursor == 0 {
+		return keys, "", nil
+	}
+	return keys, strconv.FormatUint(redisCursor, 10), nil
+}
+
+func (store *redisKVStore) namespacedKey(key string) string {
+	return fmt.Sprintf("%s:%s", store.namespace, key)
 }
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisKVStore(t *testing.T, namespace string) (*redisKVStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client, err := NewRedisClient(mr.Addr(), "", 0)
+	assert.NoError(t, err)
+	store, err := NewRedisKVStore(client, namespace)
+	assert.NoError(t, err)
+	return store, mr
+}
+
+func TestRedisKVStore(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisKVStore(t, "target")
+
+	err := store.Put(ctx, "key1", "value1")
+	assert.NoError(t, err)
+	assert.True(t, mr.Exists("target:key1"))
+
+	val, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+
+	_, err = store.Get(ctx, "notfoundkey")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	exists, err := store.Exists(ctx, "key1")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	err = store.Delete(ctx, "key1")
+	assert.NoError(t, err)
+	exists, err = store.Exists(ctx, "key1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRedisKVStore_PutIfAbsent(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestRedisKVStore(t, "target")
+
+	stored, err := store.PutIfAbsent(ctx, "key1", "value1", 0)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+
+	stored, err = store.PutIfAbsent(ctx, "key1", "value2", 0)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+
+	val, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+}
+
+func TestRedisKVStore_ReplaceIf(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisKVStore(t, "target")
+
+	replaced, err := store.ReplaceIf(ctx, "key1", "", "value1", 0)
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+	assert.False(t, mr.Exists("target:key1"))
+
+	assert.NoError(t, store.Put(ctx, "key1", "value1"))
+	replaced, err = store.ReplaceIf(ctx, "key1", "other", "value2", 0)
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+	replaced, err = store.ReplaceIf(ctx, "key1", "value1", "value2", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+
+	val, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", val)
+	assert.Equal(t, time.Minute, mr.TTL("target:key1"))
+
+	// zero ttl clears the expiry
+	replaced, err = store.ReplaceIf(ctx, "key1", "value2", "value3", 0)
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+	assert.Equal(t, time.Duration(0), mr.TTL("target:key1"))
+}
+
+func TestRedisKVStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisKVStore(t, "target")
+
+	_, err := store.PutIfAbsent(ctx, "temp", "value1", time.Minute)
+	assert.NoError(t, err)
+	_, err = store.PutIfAbsent(ctx, "perm", "value2", 0)
+	assert.NoError(t, err)
+
+	ttl, err := store.TTL(ctx, "temp")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	ttl, err = store.TTL(ctx, "perm")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	_, err = store.TTL(ctx, "notfoundkey")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// Put keeps the expiry
+	err = store.Put(ctx, "temp", "value3")
+	assert.NoError(t, err)
+
+	mr.FastForward(time.Minute)
+	_, err = store.Get(ctx, "temp")
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := store.Get(ctx, "perm")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", val)
+}
+
+func TestRedisKVStore_Scan(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisKVStore(t, "target")
+	mr.Set("short:other", "value")
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Put(ctx, key, "value"))
+	}
+
+	keys := make([]string, 0)
+	cursor := ""
+	for {
+		page, next, err := store.Scan(ctx, cursor, 2)
+		assert.NoError(t, err)
+		keys = append(keys, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, keys)
+
+	_, _, err := store.Scan(ctx, "not-a-cursor", 2)
+	assert.Equal(t, ErrInvalidCursor, err)
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewGoMapSequence creates an in-process Sequence, for use along with the
+// goMapStore
+func NewGoMapSequence() Sequence {
+	return &goMapSequence{}
+}
+
+type goMapSequence struct {
+	last atomic.Int64
+}
+
+func (s *goMapSequence) Next(_ context.Context) (int64, error) {
+	return s.last.Add(1), nil
+}
+
+// redisSequence increments a redis key with INCR, so that numbers are unique
+// across instances using the same redis
+type redisSequence struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisSequence(client *redis.Client, namespace string, name string) (*redisSequence, error) {
+	return &redisSequence{
+		client: client,
+		key:    fmt.Sprintf("%s:%s", namespace, name),
+	}, nil
+}
+
+func (s *redisSequence) Next(ctx context.Context) (int64, error) {
+	return s.client.Incr(ctx, s.key).Result()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, _ := NewRedisClient(mr.Addr(), "", 0)
+	redisSequence, _ := NewRedisSequence(client, "seq", "short_path")
+	sequences := map[string]Sequence{
+		"gomap": NewGoMapSequence(),
+		"redis": redisSequence,
+	}
+	for name, sequence := range sequences {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var mu sync.Mutex
+			seen := make(map[int64]bool)
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						n, err := sequence.Next(ctx)
+						assert.NoError(t, err)
+						mu.Lock()
+						assert.False(t, seen[n], "%d repeated", n)
+						seen[n] = true
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			for n := int64(1); n <= 200; n++ {
+				assert.True(t, seen[n], "%d missing", n)
+			}
+		})
+	}
+	value, err := mr.Get("seq:short_path")
+	assert.NoError(t, err)
+	assert.Equal(t, "200", value)
+}
@@ -3,17 +3,63 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
-	ErrKeyNotFound = errors.New("key not found")
+	ErrKeyNotFound   = errors.New("key not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
 )
 
 // KVStore is simple key value store interface that encapsulates
 // underlying logic of kv-store functionality
 type KVStore interface {
+	// Put stores the value, keeping the expiry of the key if it is already set
 	Put(ctx context.Context, key string, value string) error
+	// PutIfAbsent atomically stores the value only if the key does not exist.
+	// It returns false if the key already exists. The key expires after ttl,
+	// zero ttl means the key never expires.
+	PutIfAbsent(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+	// ReplaceIf atomically stores the value only if the key holds old. It
+	// returns false otherwise. The key expires after ttl, zero ttl means the
+	// key never expires.
+	ReplaceIf(ctx context.Context, key string, old string, value string, ttl time.Duration) (bool, error)
 	Get(ctx context.Context, key string) (string, error)
 	Exists(ctx context.Context, key string) (bool, error)
 	Delete(ctx context.Context, key string) error
+	// TTL returns the remaining time to live of the key, zero if the key never expires
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Scan iterates over the keys a page at a time. Empty cursor starts the
+	// iteration, it returns about count keys along with the cursor of the
+	// next page, which is empty once the iteration is complete. Keys added
+	// or deleted during the iteration may or may not be returned.
+	Scan(ctx context.Context, cursor string, count int) ([]string, string, error)
+}
+
+// CounterStore keeps counters, identified by field, grouped under a key
+type CounterStore interface {
+	Incr(ctx context.Context, key string, field string, delta int64) error
+	// GetAll returns all the counters of the key, empty map if there are none
+	GetAll(ctx context.Context, key string) (map[string]int64, error)
+	// Delete removes all the counters of the key
+	Delete(ctx context.Context, key string) error
+}
+
+// RankStore keeps members grouped under a key, ranked by their score
+type RankStore interface {
+	IncrScore(ctx context.Context, key string, member string, delta int64) error
+	// TopN returns up to n members of the key with the highest scores, in
+	// decreasing order of score
+	TopN(ctx context.Context, key string, n int) ([]RankedMember, error)
+}
+
+type RankedMember struct {
+	Member string
+	Score  int64
+}
+
+// Sequence generates increasing numbers, starting at 1, never repeating
+// a number
+type Sequence interface {
+	Next(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,45 @@
+package svc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/thenilesh/url-shortner/store"
+)
+
+// aliasScanCount is the number of shortPaths scanned at a time for aliases
+const aliasScanCount = 1000
+
+// IndexAliases adds the lower case alias of every mixed-case shortPath in
+// targetURLStore to aliasStore, so that case-insensitive mode resolves them
+// in any case and rejects new shortPaths differing from them only in case.
+// It returns the number of aliases added. Mixed-case shortPaths are created
+// only while the mode is off, hence it needs to run once the mode is on.
+func IndexAliases(ctx context.Context, targetURLStore store.KVStore, aliasStore store.KVStore) (int, error) {
+	added := 0
+	cursor := ""
+	for {
+		shortPaths, next, err := targetURLStore.Scan(ctx, cursor, aliasScanCount)
+		if err != nil {
+			return added, NewErrServerError("could not scan shortpaths", err)
+		}
+		for _, shortPath := range shortPaths {
+			alias := strings.ToLower(shortPath)
+			if alias == shortPath {
+				continue
+			}
+			// Of the shortPaths differing only in case, the first one wins
+			claimed, err := aliasStore.PutIfAbsent(ctx, alias, shortPath, 0)
+			if err != nil {
+				return added, NewErrServerError("could not save shortpath alias", err)
+			}
+			if claimed {
+				added++
+			}
+		}
+		if next == "" {
+			return added, nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,233 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/thenilesh/url-shortner/store"
+)
+
+// Styles of generated shortPaths
+const (
+	// StyleRandom generates random strings of charset like 2snr0e
+	StyleRandom = "random"
+	// StyleWords generates words like brave-otter-42
+	StyleWords = "words"
+	// StyleCounter encodes numbers of a sequence in charset, available
+	// with URLShortnerBuilder.SetCounterIDs
+	StyleCounter = "counter"
+)
+
+// IDStrategy generates the shortPath when none is requested
+type IDStrategy interface {
+	// NextID generates a shortPath, attempt is the number of shortPaths
+	// already found taken for the same request
+	NextID(ctx context.Context, attempt int) (string, error)
+	// Observe reports whether the generated shortPath was taken
+	Observe(collided bool)
+}
+
+const (
+	// Weight of the latest observation in collision rate
+	collisionRateAlpha = 0.05
+	// Collision rate beyond which random shortPaths get longer
+	collisionRateThreshold = 0.25
+)
+
+// randomIDStrategy generates random shortPaths, which may collide. As the
+// keyspace saturates, collisions get frequent, so the minimum length of
+// shortPaths grows, up to maxLength, whenever the moving average of the
+// collision rate crosses collisionRateThreshold. Retries of a request are
+// also generated longer, one character per attempt.
+type randomIDStrategy struct {
+	mu            sync.Mutex
+	randomStrGen  RandomStrGen
+	minLength     int
+	maxLength     int
+	collisionRate float64
+}
+
+func newRandomIDStrategy(randomStrGen RandomStrGen, minLength int, maxLength int) *randomIDStrategy {
+	return &randomIDStrategy{
+		randomStrGen: randomStrGen,
+		minLength:    minLength,
+		maxLength:    maxLength,
+	}
+}
+
+func (r *randomIDStrategy) NextID(_ context.Context, attempt int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.randomStrGen.GenerateAtLeast(r.minLength + attempt), nil
+}
+
+func (r *randomIDStrategy) Observe(collided bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	observed := 0.0
+	if collided {
+		observed = 1
+	}
+	r.collisionRate = (1-collisionRateAlpha)*r.collisionRate + collisionRateAlpha*observed
+	if r.collisionRate > collisionRateThreshold && r.minLength < r.maxLength {
+		r.minLength++
+		r.collisionRate = 0
+	}
+}
+
+// Multipliers and increments of the affine permutations obfuscating counter
+// IDs. The multipliers are primes larger than any charset, hence coprime to
+// every power of charset length, which makes the permutations reversible.
+var (
+	permMultipliers = [2]*big.Int{big.NewInt(2147483629), big.NewInt(1000000007)}
+	permIncrements  = [2]*big.Int{big.NewInt(1442695041), big.NewInt(6364136223)}
+)
+
+// counterIDStrategy encodes numbers of a Sequence in charset, so that IDs
+// are unique without checking for existing shortPaths. IDs take every
+// string of minLength first, then every string of minLength+1, and so on.
+// When obfuscated, IDs of same length are shuffled with a reversible
+// permutation so that they are not trivially sequential.
+type counterIDStrategy struct {
+	sequence  store.Sequence
+	charset   string
+	minLength int
+	obfuscate bool
+}
+
+func newCounterIDStrategy(sequence store.Sequence, charset string, minLength int, obfuscate bool) (*counterIDStrategy, error) {
+	if len(charset) < 2 {
+		return nil, errors.New("charset must have at least 2 characters")
+	}
+	for i := 0; i < len(charset); i++ {
+		if indexOf(charset, charset[i]) != i {
+			return nil, errors.New("charset must not repeat characters")
+		}
+	}
+	return &counterIDStrategy{
+		sequence:  sequence,
+		charset:   charset,
+		minLength: minLength,
+		obfuscate: obfuscate,
+	}, nil
+}
+
+// Observe has nothing to adapt, as counter IDs don't collide with each other
+func (c *counterIDStrategy) Observe(collided bool) {}
+
+func (c *counterIDStrategy) NextID(ctx context.Context, _ int) (string, error) {
+	n, err := c.sequence.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	if n < 1 {
+		return "", errors.New("sequence must start at 1")
+	}
+	return c.encode(big.NewInt(n - 1)), nil
+}
+
+// encode maps index to a string of charset, one to one
+func (c *counterIDStrategy) encode(index *big.Int) string {
+	base := big.NewInt(int64(len(c.charset)))
+	length := c.minLength
+	size := new(big.Int).Exp(base, big.NewInt(int64(length)), nil)
+	i := new(big.Int).Set(index)
+	// Skip the blocks of shorter strings
+	for i.Cmp(size) >= 0 {
+		i.Sub(i, size)
+		size.Mul(size, base)
+		length++
+	}
+	if c.obfuscate {
+		i = permute(i, size, length, base)
+	}
+	return c.digits(i, length, base)
+}
+
+// decode reverses encode, it returns false if id isn't made of charset
+func (c *counterIDStrategy) decode(id string) (*big.Int, bool) {
+	if len(id) < c.minLength {
+		return nil, false
+	}
+	base := big.NewInt(int64(len(c.charset)))
+	i := new(big.Int)
+	for k := 0; k < len(id); k++ {
+		d := indexOf(c.charset, id[k])
+		if d < 0 {
+			return nil, false
+		}
+		i.Mul(i, base).Add(i, big.NewInt(int64(d)))
+	}
+	size := new(big.Int).Exp(base, big.NewInt(int64(len(id))), nil)
+	if c.obfuscate {
+		i = unpermute(i, size, len(id), base)
+	}
+	// Add the blocks of shorter strings
+	for length := c.minLength; length < len(id); length++ {
+		i.Add(i, new(big.Int).Exp(base, big.NewInt(int64(length)), nil))
+	}
+	return i, true
+}
+
+func (c *counterIDStrategy) digits(i *big.Int, length int, base *big.Int) string {
+	b := make([]byte, length)
+	x := new(big.Int).Set(i)
+	d := new(big.Int)
+	for k := length - 1; k >= 0; k-- {
+		x.DivMod(x, base, d)
+		b[k] = c.charset[d.Int64()]
+	}
+	return string(b)
+}
+
+func indexOf(charset string, ch byte) int {
+	for i := 0; i < len(charset); i++ {
+		if charset[i] == ch {
+			return i
+		}
+	}
+	return -1
+}
+
+// permute shuffles i within [0, size) by an affine permutation, reversal of
+// digits and another affine permutation. Reversing the digits lets the
+// second permutation spread the low digits, which the first one leaves
+// periodic.
+func permute(i *big.Int, size *big.Int, length int, base *big.Int) *big.Int {
+	x := affine(i, size, 0)
+	x = reverseDigits(x, length, base)
+	return affine(x, size, 1)
+}
+
+func unpermute(i *big.Int, size *big.Int, length int, base *big.Int) *big.Int {
+	x := inverseAffine(i, size, 1)
+	x = reverseDigits(x, length, base)
+	return inverseAffine(x, size, 0)
+}
+
+func affine(x *big.Int, size *big.Int, round int) *big.Int {
+	y := new(big.Int).Mul(x, permMultipliers[round])
+	y.Add(y, permIncrements[round])
+	return y.Mod(y, size)
+}
+
+func inverseAffine(y *big.Int, size *big.Int, round int) *big.Int {
+	inverse := new(big.Int).ModInverse(permMultipliers[round], size)
+	x := new(big.Int).Sub(y, permIncrements[round])
+	x.Mod(x, size)
+	x.Mul(x, inverse)
+	return x.Mod(x, size)
+}
+
+func reverseDigits(x *big.Int, length int, base *big.Int) *big.Int {
+	y := new(big.Int)
+	rest := new(big.Int).Set(x)
+	d := new(big.Int)
+	for k := 0; k < length; k++ {
+		rest.DivMod(rest, base, d)
+		y.Mul(y, base).Add(y, d)
+	}
+	return y
+}
@@ -0,0 +1,109 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thenilesh/url-shortner/store"
+)
+
+func TestCounterIDStrategy_NextID(t *testing.T) {
+	ctx := context.Background()
+	strategy, err := newCounterIDStrategy(store.NewGoMapSequence(), "ab", 2, false)
+	assert.NoError(t, err)
+
+	expected := []string{"aa", "ab", "ba", "bb", "aaa", "aab", "aba"}
+	for _, exp := range expected {
+		id, err := strategy.NextID(ctx, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, exp, id)
+	}
+}
+
+func TestCounterIDStrategy_obfuscate(t *testing.T) {
+	for _, obfuscate := range []bool{false, true} {
+		strategy, err := newCounterIDStrategy(store.NewGoMapSequence(), "abc123", 2, obfuscate)
+		assert.NoError(t, err)
+
+		seen := make(map[string]bool)
+		sequential := 0
+		previous := ""
+		for n := int64(0); n < 5000; n++ {
+			id := strategy.encode(big.NewInt(n))
+			assert.False(t, seen[id], "%s repeated", id)
+			seen[id] = true
+
+			decoded, ok := strategy.decode(id)
+			assert.True(t, ok)
+			assert.Equal(t, n, decoded.Int64(), "decode %s", id)
+
+			// IDs differing only in the last character look sequential
+			if previous != "" && len(previous) == len(id) && previous[:len(id)-1] == id[:len(id)-1] {
+				sequential++
+			}
+			previous = id
+		}
+		if obfuscate {
+			assert.Less(t, sequential, 500, "obfuscated IDs look sequential")
+		} else {
+			assert.Greater(t, sequential, 4000)
+		}
+	}
+}
+
+func TestCounterIDStrategy_decode_invalid(t *testing.T) {
+	strategy, _ := newCounterIDStrategy(store.NewGoMapSequence(), "abc", 3, true)
+	_, ok := strategy.decode("ab")
+	assert.False(t, ok)
+	_, ok = strategy.decode("abz")
+	assert.False(t, ok)
+}
+
+func TestNewCounterIDStrategy_invalidCharset(t *testing.T) {
+	_, err := newCounterIDStrategy(store.NewGoMapSequence(), "a", 2, false)
+	assert.EqualError(t, err, "charset must have at least 2 characters")
+	_, err = newCounterIDStrategy(store.NewGoMapSequence(), "aba", 2, false)
+	assert.EqualError(t, err, "charset must not repeat characters")
+}
+
+func TestCounterIDStrategy_sequenceError(t *testing.T) {
+	strategy, _ := newCounterIDStrategy(failingSequence{}, "abc", 2, false)
+	_, err := strategy.NextID(context.Background(), 0)
+	assert.Error(t, err)
+}
+
+type failingSequence struct{}
+
+func (failingSequence) Next(ctx context.Context) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestRandomIDStrategy_Observe(t *testing.T) {
+	strategy := newRandomIDStrategy(NewRandomStrGen(2, 4, "abc"), 2, 4)
+
+	for i := 0; i < 100; i++ {
+		strategy.Observe(false)
+	}
+	assert.Equal(t, 2, strategy.minLength)
+
+	// Frequent collisions make shortPaths longer, but not beyond maxLength
+	for i := 0; i < 1000; i++ {
+		strategy.Observe(true)
+	}
+	assert.Equal(t, 4, strategy.minLength)
+	id, err := strategy.NextID(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, id, 4)
+}
+
+func TestRandomIDStrategy_NextID_attempt(t *testing.T) {
+	strategy := newRandomIDStrategy(NewRandomStrGen(2, 5, "abc"), 2, 5)
+	for i := 0; i < 20; i++ {
+		id, err := strategy.NextID(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, len(id), 4)
+	}
+}
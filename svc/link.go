@@ -0,0 +1,50 @@
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// linkVersion is the version of Link record written by this code.
+// Bump it when Link changes in a way older code can not read.
+const linkVersion = 1
+
+// Link is the record stored against shortPath in targetURLStore
+type Link struct {
+	// Version is 0 for legacy records that hold only the targetURL
+	Version   int        `json:"v"`
+	TargetURL string     `json:"target_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Disabled  bool       `json:"disabled,omitempty"`
+}
+
+func encodeLink(link Link) (string, error) {
+	link.Version = linkVersion
+	dataBytes, err := json.Marshal(link)
+	if err != nil {
+		return "", err
+	}
+	return string(dataBytes), nil
+}
+
+// decodeLink decodes the Link record. Values written before Link record was
+// introduced are plain targetURLs, those are decoded as Link of version 0.
+func decodeLink(value string) (Link, error) {
+	var link Link
+	if !strings.HasPrefix(value, "{") {
+		link.TargetURL = value
+		return link, nil
+	}
+	if err := json.Unmarshal([]byte(value), &link); err != nil {
+		return link, err
+	}
+	if link.Version > linkVersion {
+		return link, fmt.Errorf("unsupported link record version %d", link.Version)
+	}
+	return link, nil
+}
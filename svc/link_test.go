@@ -0,0 +1,42 @@
+package svc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkCodec(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	link := Link{
+		TargetURL: "https://www.google.com",
+		CreatedAt: time.Date(2023, 10, 1, 10, 0, 0, 0, time.UTC),
+		CreatedBy: "nilesh",
+		ExpiresAt: &expiresAt,
+		Tags:      []string{"campaign"},
+	}
+	value, err := encodeLink(link)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"v":1,"target_url":"https://www.google.com","created_at":"2023-10-01T10:00:00Z",`+
+		`"created_by":"nilesh","expires_at":"2024-01-01T00:00:00Z","tags":["campaign"]}`, value)
+
+	decoded, err := decodeLink(value)
+	assert.NoError(t, err)
+	link.Version = linkVersion
+	assert.Equal(t, link, decoded)
+}
+
+func TestDecodeLink_legacy(t *testing.T) {
+	link, err := decodeLink("https://www.google.com")
+	assert.NoError(t, err)
+	assert.Equal(t, Link{TargetURL: "https://www.google.com"}, link)
+}
+
+func TestDecodeLink_errors(t *testing.T) {
+	_, err := decodeLink(`{"v":2,"target_url":"https://www.google.com"}`)
+	assert.EqualError(t, err, "unsupported link record version 2")
+
+	_, err = decodeLink(`{"v":1,`)
+	assert.Error(t, err)
+}
@@ -2,18 +2,27 @@ package svc
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
 type RandomStrGen interface {
 	Generate() string
+	// GenerateAtLeast generates a string not shorter than minLength, it is
+	// clamped between the minimum and maximum length of the generator
+	GenerateAtLeast(minLength int) string
 }
 
+// randomStrGen generates strings with math/rand, which is fast but
+// predictable. Use NewSecureRandomStrGen where shortPaths must not be
+// guessable.
 type randomStrGen struct {
 	minLength int
 	maxLength int
 	charset   string
-	rand      *rand.Rand
+	// Guards rand, which is not safe for concurrent use
+	mu   sync.Mutex
+	rand *rand.Rand
 }
 
 func NewRandomStrGen(minLength int, maxLength int, charset string) RandomStrGen {
@@ -26,9 +35,21 @@ func NewRandomStrGen(minLength int, maxLength int, charset string) RandomStrGen
 }
 
 func (r *randomStrGen) Generate() string {
-	length := r.minLength
-	if r.maxLength > r.minLength {
-		length = r.minLength + r.rand.Intn(r.maxLength-r.minLength+1)
+	return r.GenerateAtLeast(r.minLength)
+}
+
+func (r *randomStrGen) GenerateAtLeast(minLength int) string {
+	if minLength < r.minLength {
+		minLength = r.minLength
+	}
+	if minLength > r.maxLength {
+		minLength = r.maxLength
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	length := minLength
+	if r.maxLength > minLength {
+		length = minLength + r.rand.Intn(r.maxLength-minLength+1)
 	}
 	b := make([]byte, length)
 	for i := range b {
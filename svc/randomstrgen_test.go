@@ -55,3 +55,23 @@ func contains(s string, c string) bool {
 	}
 	return false
 }
+
+func TestRandomStrGen_GenerateAtLeast(t *testing.T) {
+	r := NewRandomStrGen(2, 6, "abc")
+	tests := []struct {
+		minLength int
+		expMin    int
+	}{
+		{minLength: 0, expMin: 2},
+		{minLength: 4, expMin: 4},
+		{minLength: 9, expMin: 6},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := r.GenerateAtLeast(tt.minLength)
+			if len(got) < tt.expMin || len(got) > 6 {
+				t.Errorf("GenerateAtLeast(%d) = %q, want string of length between %d and 6", tt.minLength, got, tt.expMin)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package svc
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// secureStrGen generates strings with crypto/rand, so that generated
+// shortPaths can't be predicted from previous ones. It is safe for
+// concurrent use.
+type secureStrGen struct {
+	minLength int
+	maxLength int
+	charset   string
+	random    io.Reader
+}
+
+func NewSecureRandomStrGen(minLength int, maxLength int, charset string) RandomStrGen {
+	return &secureStrGen{
+		minLength: minLength,
+		maxLength: maxLength,
+		charset:   charset,
+		random:    rand.Reader,
+	}
+}
+
+func (s *secureStrGen) Generate() string {
+	return s.GenerateAtLeast(s.minLength)
+}
+
+// GenerateAtLeast panics if system's secure random source fails, as there
+// is no safe fallback
+func (s *secureStrGen) GenerateAtLeast(minLength int) string {
+	if minLength < s.minLength {
+		minLength = s.minLength
+	}
+	if minLength > s.maxLength {
+		minLength = s.maxLength
+	}
+	length := minLength
+	if s.maxLength > minLength {
+		n, err := rand.Int(s.random, big.NewInt(int64(s.maxLength-minLength+1)))
+		if err != nil {
+			panic(fmt.Sprintf("crypto/rand failed: %v", err))
+		}
+		length = minLength + int(n.Int64())
+	}
+	indices, err := uniformIndices(s.random, len(s.charset), length)
+	if err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	b := make([]byte, length)
+	for i, index := range indices {
+		b[i] = s.charset[index]
+	}
+	return string(b)
+}
+
+// uniformIndices reads count indices in [0, n) from random, n must not
+// exceed 256. Taking a random byte modulo n would favour the lower indices
+// when 256 is not a multiple of n, so bytes beyond the largest multiple of
+// n are rejected and read again.
+func uniformIndices(random io.Reader, n int, count int) ([]int, error) {
+	limit := 256 - 256%n
+	indices := make([]int, 0, count)
+	buf := make([]byte, count)
+	for len(indices) < count {
+		if _, err := io.ReadFull(random, buf[:count-len(indices)]); err != nil {
+			return nil, err
+		}
+		for _, b := range buf[:count-len(indices)] {
+			if int(b) < limit {
+				indices = append(indices, int(b)%n)
+			}
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,82 @@
+package svc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecureStrGen_Generate(t *testing.T) {
+	r := NewSecureRandomStrGen(4, 8, "abc123")
+	lengths := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		got := r.Generate()
+		lengths[len(got)] = true
+		assert.GreaterOrEqual(t, len(got), 4)
+		assert.LessOrEqual(t, len(got), 8)
+		for _, c := range got {
+			assert.Contains(t, "abc123", string(c))
+		}
+	}
+	assert.Len(t, lengths, 5, "Expected every length between 4 and 8")
+
+	assert.Len(t, r.GenerateAtLeast(8), 8)
+}
+
+func TestUniformIndices_rejectsBiasedBytes(t *testing.T) {
+	// With n = 100, bytes from 200 to 255 would favour indices below 56
+	random := bytes.NewReader([]byte{250, 7, 200, 199, 255, 42})
+	indices, err := uniformIndices(random, 100, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{7, 99, 42}, indices)
+}
+
+func TestUniformIndices_uniform(t *testing.T) {
+	r := NewSecureRandomStrGen(1, 1, "abc").(*secureStrGen)
+	const n, samples = 3, 30000
+	counts := make([]int, n)
+	indices, err := uniformIndices(r.random, n, samples)
+	assert.NoError(t, err)
+	for _, index := range indices {
+		counts[index]++
+	}
+	for i, count := range counts {
+		// Expected 10000 each, allow for 5 standard deviations
+		assert.InDelta(t, samples/n, count, 410, "index %d", i)
+	}
+}
+
+func TestUniformIndices_readError(t *testing.T) {
+	_, err := uniformIndices(bytes.NewReader([]byte{1}), 10, 3)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+// TestStrGen_concurrent must be run with -race to detect unsynchronized
+// access of the random source
+func TestStrGen_concurrent(t *testing.T) {
+	gens := map[string]RandomStrGen{
+		"math":   NewRandomStrGen(4, 7, "abc"),
+		"secure": NewSecureRandomStrGen(4, 7, "abc"),
+		"words":  NewWordStrGen(),
+	}
+	for name, gen := range gens {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						gen.Generate()
+						gen.GenerateAtLeast(5)
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package svc
+
+import (
+	"bufio"
+	_ "embed"
+	"os"
+	"strings"
+)
+
+//go:embed wordlists/blocked.txt
+var blockedList string
+
+// routeWords are the first path segments of the routes served along with
+// shortPaths, they are always reserved
+var routeWords = []string{"api", "health", "metrics"}
+
+// leetReplacer maps characters commonly used to disguise words to the
+// letters they resemble, and removes separators
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b",
+	"-", "", "_", "",
+)
+
+// shortPathFilter rejects reserved shortPaths, and shortPaths containing
+// a blocked word
+type shortPathFilter struct {
+	reserved map[string]bool
+	blocked  []string
+}
+
+func newShortPathFilter(reserved []string, blocked []string) *shortPathFilter {
+	f := &shortPathFilter{
+		reserved: make(map[string]bool),
+	}
+	for _, word := range routeWords {
+		f.reserved[word] = true
+	}
+	for _, word := range reserved {
+		f.reserved[strings.ToLower(strings.TrimSpace(word))] = true
+	}
+	for _, word := range blocked {
+		if word = normalizeWord(word); word != "" {
+			f.blocked = append(f.blocked, word)
+		}
+	}
+	return f
+}
+
+// check returns ErrValidation if shortPath is not allowed
+func (f *shortPathFilter) check(shortPath string) error {
+	if f.reserved[strings.ToLower(shortPath)] {
+		return NewErrValidation("short_path is reserved")
+	}
+	normalized := normalizeWord(shortPath)
+	for _, word := range f.blocked {
+		if strings.Contains(normalized, word) {
+			return NewErrValidation("short_path contains a blocked word")
+		}
+	}
+	return nil
+}
+
+func normalizeWord(word string) string {
+	return leetReplacer.Replace(strings.ToLower(strings.TrimSpace(word)))
+}
+
+// DefaultBlockedWords returns the embedded list of blocked words
+func DefaultBlockedWords() []string {
+	return parseWordList(blockedList)
+}
+
+// LoadWordList reads a file of one word per line, ignoring blank lines and
+// lines starting with #
+func LoadWordList(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseWordList(string(content)), nil
+}
+
+func parseWordList(content string) []string {
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words
+}
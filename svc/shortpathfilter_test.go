@@ -0,0 +1,137 @@
+package svc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appmetrics "github.com/thenilesh/url-shortner/metrics"
+	"github.com/thenilesh/url-shortner/mocks"
+	"github.com/thenilesh/url-shortner/store"
+)
+
+func TestShortPathFilter_check(t *testing.T) {
+	f := newShortPathFilter([]string{"Admin", " login "}, DefaultBlockedWords())
+
+	tests := []struct {
+		shortPath string
+		errMsg    string
+	}{
+		{shortPath: "metrics", errMsg: "short_path is reserved"},
+		{shortPath: "API", errMsg: "short_path is reserved"},
+		{shortPath: "health", errMsg: "short_path is reserved"},
+		{shortPath: "admin", errMsg: "short_path is reserved"},
+		{shortPath: "login", errMsg: "short_path is reserved"},
+		{shortPath: "holyshit", errMsg: "short_path contains a blocked word"},
+		{shortPath: "SH1T-happens", errMsg: "short_path contains a blocked word"},
+		{shortPath: "f_u_c_k", errMsg: "short_path contains a blocked word"},
+		{shortPath: "metrics-report"},
+		{shortPath: "apiary"},
+		{shortPath: "assessment"},
+		{shortPath: "classic"},
+		{shortPath: "2snr0e"},
+	}
+	for _, tt := range tests {
+		err := f.check(tt.shortPath)
+		if tt.errMsg == "" {
+			assert.NoError(t, err, tt.shortPath)
+			continue
+		}
+		assert.IsType(t, &ErrValidation{}, err, tt.shortPath)
+		assert.EqualError(t, err, tt.errMsg, tt.shortPath)
+	}
+}
+
+func TestShortPathFilter_words(t *testing.T) {
+	f := newShortPathFilter(nil, DefaultBlockedWords())
+	adjectives := strings.Fields(adjectivesList)
+	nouns := strings.Fields(nounsList)
+	for _, adjective := range adjectives {
+		for _, noun := range nouns {
+			shortPath := adjective + "-" + noun + "-42"
+			assert.NoError(t, f.check(shortPath), shortPath)
+		}
+	}
+}
+
+func TestLoadWordList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reserved.txt")
+	content := "# reserved by marketing\nlogin\n\n  admin  \n#signup\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	words, err := LoadWordList(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"login", "admin"}, words)
+
+	_, err = LoadWordList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+// fixedIDStrategy generates the ids in order
+type fixedIDStrategy struct {
+	ids []string
+}
+
+func (f *fixedIDStrategy) NextID(_ context.Context, _ int) (string, error) {
+	id := f.ids[0]
+	f.ids = f.ids[1:]
+	return id, nil
+}
+
+func (f *fixedIDStrategy) Observe(collided bool) {}
+
+func TestURLShortner_CreateShortPath_filterGenerated(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(store.NewGoMapStore()).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetMetrics(metrics).
+		SetReservedWords([]string{"admin"}).
+		Build()
+	assert.NoError(t, err)
+	shortner.(*urlShortner).idStrategies[StyleRandom] = &fixedIDStrategy{ids: []string{"api", "5hitx", "admin", "k3ep"}}
+
+	// Offending ids are regenerated silently
+	shortPath, err := shortner.CreateShortPath(ctx, "", "https://example.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "k3ep", shortPath)
+
+	// but requested ones are rejected
+	_, err = shortner.CreateShortPath(ctx, "Admin", "https://example.org", CreateOptions{})
+	assert.EqualError(t, err, "short_path is reserved")
+	_, err = shortner.CreateShortPath(ctx, "bullsh1t", "https://example.org", CreateOptions{})
+	assert.EqualError(t, err, "short_path contains a blocked word")
+}
+
+func TestURLShortner_CreateShortPath_filterGiveUp(t *testing.T) {
+	ctx := context.Background()
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(store.NewGoMapStore()).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetMetrics(new(mocks.Metrics)).
+		Build()
+	assert.NoError(t, err)
+	ids := make([]string, maxFilteredIDs)
+	for i := range ids {
+		ids[i] = "metrics"
+	}
+	shortner.(*urlShortner).idStrategies[StyleRandom] = &fixedIDStrategy{ids: ids}
+	shortner.(*urlShortner).metrics.(*mocks.Metrics).On("GetCounter", appmetrics.ShortPathAttemptsTotal).
+		Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+
+	_, err = shortner.CreateShortPath(ctx, "", "https://example.com", CreateOptions{})
+	assert.IsType(t, &ErrServerError{}, err)
+}
@@ -2,103 +2,474 @@ package svc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/thenilesh/url-shortner/metrics"
 	"github.com/thenilesh/url-shortner/store"
 )
 
+const (
+	// Generated IDs rejected by the filter before giving up
+	maxFilteredIDs = 10
+	// Pages of the store scanned for a page of ShortURLs
+	maxScannedPages = 10
+)
+
+// errShortPathTaken is returned by doShorten when shortPath is already claimed
+var errShortPathTaken = errors.New("shortpath already taken")
+
 type URLShortner interface {
 	GetTargetURL(ctx context.Context, shortPath string) (string, error)
-	CreateShortPath(ctx context.Context, shortPath string, targetURL string) (string, error)
+	// ResolveShortPath returns the key shortPath is stored by, which clicks
+	// are tracked under, along with its targetURL
+	ResolveShortPath(ctx context.Context, shortPath string) (string, string, error)
+	GetShortURL(ctx context.Context, shortPath string) (*ShortURL, error)
+	CreateShortPath(ctx context.Context, shortPath string, targetURL string, opts CreateOptions) (string, error)
+	UpdateTargetURL(ctx context.Context, shortPath string, targetURL string) error
+	DeleteShortPath(ctx context.Context, shortPath string) error
+	ListShortURLs(ctx context.Context, opts ListOptions) (*ShortURLPage, error)
+}
+
+// ShortURL is a shortPath along with the attributes of its link
+type ShortURL struct {
+	ShortPath string
+	TargetURL string
+	// Zero value means creation time is not known
+	CreatedAt time.Time
+	CreatedBy string
+	// Zero value means the shortPath never expires
+	ExpiresAt time.Time
+	Tags      []string
+	Clicks    int64
+}
+
+// ListOptions selects a page of ShortURLs
+type ListOptions struct {
+	// Cursor returned along with the previous page, empty for the first page
+	Cursor string
+	// Limit is the number of ShortURLs a page is filled up to. Stores
+	// scanning in batches, like redis, may exceed it.
+	Limit int
+	// Query filters ShortURLs whose shortPath or domain of targetURL
+	// contains it, ignoring case. Empty matches all.
+	Query string
+}
+
+// ShortURLPage is a page of ShortURLs, in no particular order
+type ShortURLPage struct {
+	ShortURLs []ShortURL
+	// NextCursor is empty on the last page
+	NextCursor string
+}
+
+// CreateOptions holds optional attributes of the short path being created
+type CreateOptions struct {
+	// ExpiresAt is the time after which the short path stops resolving.
+	// Zero value means the short path never expires. If the short path or
+	// the target URL exists already with another expiry, it is a conflict.
+	ExpiresAt time.Time
+	CreatedBy string
+	Tags      []string
+	// Style of the generated short path, default style of URLShortner if
+	// empty. It can't be set along with a requested short path.
+	Style string
+	// DryRun validates the short path and checks it for conflicts without
+	// creating it. The short path returned is empty if it would have been
+	// generated.
+	DryRun bool
 }
 
 type urlShortner struct {
-	randomStrGen RandomStrGen
-	// Maps shortPath to targetURL
+	// Maps style to IDStrategy generating shortPaths of that style
+	idStrategies map[string]IDStrategy
+	defaultStyle string
+	filter       *shortPathFilter
+	// Resolve shortPaths regardless of their case
+	caseInsensitive bool
+	// Number of generated shortPaths tried for a request
+	retryBudget int
+	// Maps shortPath to encoded Link
 	targetURLStore store.KVStore
 	// Maps targetURL to shortPath
 	shortPathStore store.KVStore
-	metrics        metrics.Metrics
+	// Maps lower case of mixed-case shortPaths, stored before the
+	// case-insensitive mode, to them
+	aliasStore store.KVStore
+	metrics    metrics.Metrics
+	now        func() time.Time
 }
 
 func (u *urlShortner) GetTargetURL(ctx context.Context, shortPath string) (string, error) {
-	targetURL, found, err := u.lookupTargetURL(ctx, shortPath)
+	_, link, err := u.getLink(ctx, shortPath)
 	if err != nil {
-		return "", NewErrServerError("could not lookup shortpath", err)
+		return "", err
 	}
-	if !found {
-		return "", NewErrNotFound("shortpath mapping not found")
+	return link.TargetURL, nil
+}
+
+func (u *urlShortner) ResolveShortPath(ctx context.Context, shortPath string) (string, string, error) {
+	key, link, err := u.getLink(ctx, shortPath)
+	if err != nil {
+		return "", "", err
 	}
-	return targetURL, nil
+	return key, link.TargetURL, nil
 }
 
-func (u *urlShortner) CreateShortPath(ctx context.Context, shortPath string, targetURL string) (string, error) {
+func (u *urlShortner) GetShortURL(ctx context.Context, shortPath string) (*ShortURL, error) {
+	shortPath, link, err := u.getLink(ctx, shortPath)
+	if err != nil {
+		return nil, err
+	}
+	return u.newShortURL(ctx, shortPath, link)
+}
+
+// ListShortURLs pages through the shortPaths, skipping the disabled ones.
+// A page may have fewer ShortURLs than the limit
+// even when it is not the last one, as few shortPaths may match the query.
+func (u *urlShortner) ListShortURLs(ctx context.Context, opts ListOptions) (*ShortURLPage, error) {
+	if opts.Limit <= 0 {
+		return nil, NewErrValidation("limit must be positive")
+	}
+	query := strings.ToLower(opts.Query)
+	page := &ShortURLPage{ShortURLs: make([]ShortURL, 0), NextCursor: opts.Cursor}
+	for i := 0; i < maxScannedPages && len(page.ShortURLs) < opts.Limit; i++ {
+		shortPaths, cursor, err := u.targetURLStore.Scan(ctx, page.NextCursor, opts.Limit-len(page.ShortURLs))
+		if err != nil {
+			if err == store.ErrInvalidCursor {
+				return nil, NewErrValidation("invalid cursor")
+			}
+			return nil, NewErrServerError("could not scan shortpaths", err)
+		}
+		for _, shortPath := range shortPaths {
+			link, found, err := u.lookupLink(ctx, shortPath)
+			if err != nil {
+				return nil, NewErrServerError("could not lookup shortpath", err)
+			}
+			// shortPath may have been deleted after the scan
+			if !found || link.Disabled || !matchesQuery(shortPath, link, query) {
+				continue
+			}
+			shortURL, err := u.newShortURL(ctx, shortPath, link)
+			if err == nil {
+				page.ShortURLs = append(page.ShortURLs, *shortURL)
+			} else if _, ok := err.(*ErrNotFound); !ok {
+				return nil, err
+			}
+		}
+		page.NextCursor = cursor
+		if cursor == "" {
+			break
+		}
+	}
+	return page, nil
+}
+
+// matchesQuery tells if shortPath or domain of targetURL of the link
+// contains the lower case query
+func matchesQuery(shortPath string, link Link, query string) bool {
+	return strings.Contains(strings.ToLower(shortPath), query) ||
+		strings.Contains(strings.ToLower(extractDomainFromURL(link.TargetURL)), query)
+}
+
+// expiresAt returns the expiry of the link, zero if it never expires
+func (u *urlShortner) expiresAt(ctx context.Context, shortPath string, link Link) (time.Time, error) {
+	if link.ExpiresAt != nil {
+		return *link.ExpiresAt, nil
+	}
+	if link.Version != 0 {
+		return time.Time{}, nil
+	}
+	// Legacy records keep the expiry only in the store
+	ttl, err := u.targetURLStore.TTL(ctx, shortPath)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return time.Time{}, NewErrNotFound("shortpath mapping not found")
+		}
+		return time.Time{}, NewErrServerError("could not lookup shortpath expiry", err)
+	}
+	if ttl <= 0 {
+		return time.Time{}, nil
+	}
+	return u.now().Add(ttl).Truncate(time.Second), nil
+}
+
+// sameExpiry tells if the existing link expires at expiresAt, to the second,
+// so that creating it again with another expiry is not taken as idempotent
+func (u *urlShortner) sameExpiry(ctx context.Context, shortPath string, link Link, expiresAt time.Time) (bool, error) {
+	existing, err := u.expiresAt(ctx, shortPath, link)
+	if err != nil {
+		return false, err
+	}
+	if existing.IsZero() || expiresAt.IsZero() {
+		return existing.IsZero() == expiresAt.IsZero(), nil
+	}
+	return existing.Truncate(time.Second).Equal(expiresAt.Truncate(time.Second)), nil
+}
+
+// newShortURL builds ShortURL of the link
+func (u *urlShortner) newShortURL(ctx context.Context, shortPath string, link Link) (*ShortURL, error) {
+	shortURL := &ShortURL{
+		ShortPath: shortPath,
+		TargetURL: link.TargetURL,
+		CreatedAt: link.CreatedAt,
+		CreatedBy: link.CreatedBy,
+		Tags:      link.Tags,
+	}
+	expiresAt, err := u.expiresAt(ctx, shortPath, link)
+	if err != nil {
+		return nil, err
+	}
+	shortURL.ExpiresAt = expiresAt
+	stats, err := u.metrics.GetClickTracker().GetClickStats(ctx, shortPath)
+	if err != nil {
+		return nil, NewErrServerError("could not get click stats", err)
+	}
+	shortURL.Clicks = stats.Total
+	return shortURL, nil
+}
+
+func (u *urlShortner) CreateShortPath(ctx context.Context, shortPath string, targetURL string, opts CreateOptions) (string, error) {
 	if err := validateShortPath(shortPath); err != nil {
 		return "", err
 	}
 	if err := validateTargetURL(targetURL); err != nil {
 		return "", err
 	}
+	if len(shortPath) > 0 {
+		if err := u.filter.check(shortPath); err != nil {
+			return "", err
+		}
+	}
+	if len(shortPath) > 0 && opts.Style != "" {
+		return "", NewErrValidation("style can not be set along with short_path")
+	}
+	style := opts.Style
+	if style == "" {
+		style = u.defaultStyle
+	}
+	idStrategy, ok := u.idStrategies[style]
+	if !ok {
+		return "", NewErrValidation(fmt.Sprintf("unknown style %s", style))
+	}
+	var ttl time.Duration
+	if !opts.ExpiresAt.IsZero() {
+		ttl = opts.ExpiresAt.Sub(u.now())
+		if ttl <= 0 {
+			return "", NewErrValidation("expires_at is in the past")
+		}
+	}
 	targetURL = removeTrailingSlash(targetURL)
 	if len(shortPath) > 0 { // isShortPathProvidedInRequest ?
-		oldTargetURL, found, err := u.lookupTargetURL(ctx, shortPath)
+		key, oldLink, found, err := u.resolveLink(ctx, shortPath)
 		if err != nil {
 			return "", NewErrServerError("could not lookup shortpath", err)
 		}
 		if found {
-			if oldTargetURL == targetURL {
-				return shortPath, nil
-			} else {
+			if oldLink.TargetURL != targetURL {
 				return "", NewErrConflict("shortpath already exists for different targetURL")
 			}
+			same, err := u.sameExpiry(ctx, key, oldLink, opts.ExpiresAt)
+			if err != nil {
+				return "", err
+			}
+			if !same {
+				return "", NewErrConflict("shortpath already exists with different expiry")
+			}
+			return key, nil
 		}
 	}
-	existingShortPath, found, err := u.lookupShortPath(ctx, targetURL)
+	existingShortPath, existingLink, found, err := u.lookupShortPath(ctx, targetURL)
 	if err != nil {
 		return "", err
 	}
 	if found { // isTargetURLAlreadyShortened
+		same, err := u.sameExpiry(ctx, existingShortPath, existingLink, opts.ExpiresAt)
+		if err != nil {
+			return "", err
+		}
+		if !same {
+			return "", NewErrConflict("targetURL is already shortened with different expiry")
+		}
 		return existingShortPath, nil
 	}
+	link := Link{
+		TargetURL: targetURL,
+		CreatedAt: u.now().UTC(),
+		CreatedBy: opts.CreatedBy,
+		Tags:      opts.Tags,
+	}
+	if !opts.ExpiresAt.IsZero() {
+		expiresAt := opts.ExpiresAt.UTC()
+		link.ExpiresAt = &expiresAt
+	}
+	shortPath = u.canonicalShortPath(shortPath)
+	if opts.DryRun {
+		return shortPath, nil
+	}
 	if len(shortPath) == 0 {
-		shortPath, err = u.findAvailableShortPath(ctx, u.targetURLStore)
+		return u.shortenWithAvailableShortPath(ctx, idStrategy, link, ttl)
+	}
+	existingShortPath, err = u.doShorten(ctx, shortPath, link, ttl)
+	if err == errShortPathTaken {
+		// Someone claimed the shortPath after our lookup
+		oldLink, found, err := u.lookupLink(ctx, shortPath)
+		if err != nil {
+			return "", NewErrServerError("could not lookup shortpath", err)
+		}
+		if !found || oldLink.TargetURL != targetURL {
+			return "", NewErrConflict("shortpath already exists for different targetURL")
+		}
+		same, err := u.sameExpiry(ctx, shortPath, oldLink, opts.ExpiresAt)
 		if err != nil {
 			return "", err
 		}
+		if !same {
+			return "", NewErrConflict("shortpath already exists with different expiry")
+		}
+		return shortPath, nil
+	}
+	return existingShortPath, err
+}
+
+// UpdateTargetURL points an existing shortPath to a new targetURL
+func (u *urlShortner) UpdateTargetURL(ctx context.Context, shortPath string, targetURL string) error {
+	if err := validateTargetURL(targetURL); err != nil {
+		return err
 	}
-	return u.doShorten(ctx, shortPath, targetURL)
+	targetURL = removeTrailingSlash(targetURL)
+	shortPath, link, found, err := u.resolveLink(ctx, shortPath)
+	if err != nil {
+		return NewErrServerError("could not lookup shortpath", err)
+	}
+	if !found {
+		return NewErrNotFound("shortpath mapping not found")
+	}
+	if link.TargetURL == targetURL {
+		return nil
+	}
+	existingShortPath, _, found, err := u.lookupShortPath(ctx, targetURL)
+	if err != nil {
+		return err
+	}
+	if found && existingShortPath != shortPath {
+		return NewErrConflict("targetURL is already shortened with different shortpath")
+	}
+	return u.doRetarget(ctx, shortPath, link, targetURL)
 }
 
-// findAvailableShortPath finds a randomly generated shortPath that is not already taken
-func (u *urlShortner) findAvailableShortPath(ctx context.Context, store store.KVStore) (string, error) {
-	var err error
-	var alreadyExists bool
-	for i := 0; i < 3; i++ {
-		shortPath := u.randomStrGen.Generate()
-		if alreadyExists, err = u.targetURLStore.Exists(ctx, shortPath); err != nil {
-			return "", NewErrServerError("could not check if shortpath exists", err)
+// DeleteShortPath removes shortPath along with the reverse mapping of its targetURL
+func (u *urlShortner) DeleteShortPath(ctx context.Context, shortPath string) error {
+	shortPath, link, found, err := u.resolveLink(ctx, shortPath)
+	if err != nil {
+		return NewErrServerError("could not lookup shortpath", err)
+	}
+	if !found {
+		return NewErrNotFound("shortpath mapping not found")
+	}
+	ttl, err := u.targetURLStore.TTL(ctx, shortPath)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return NewErrNotFound("shortpath mapping not found")
 		}
-		if !alreadyExists {
-			return shortPath, nil
+		return NewErrServerError("could not lookup shortpath expiry", err)
+	}
+	if err := u.doDelete(ctx, shortPath, link, ttl); err != nil {
+		return err
+	}
+	// Otherwise the shortPath created again would inherit the clicks
+	if err := u.metrics.GetClickTracker().DeleteClickStats(ctx, shortPath); err != nil {
+		return NewErrServerError("could not delete click stats", err)
+	}
+	if alias := u.canonicalShortPath(shortPath); alias != shortPath {
+		// Aliases of deleted shortPaths are ignored, hence failure is harmless
+		if key, err := u.aliasStore.Get(ctx, alias); err == nil && key == shortPath {
+			u.aliasStore.Delete(ctx, alias)
 		}
 	}
-	return "", NewErrServerError("failed to generate available short_path", nil)
+	return nil
 }
 
-func (u *urlShortner) doShorten(ctx context.Context, shortPath string, targetURL string) (string, error) {
+// shortenWithAvailableShortPath shortens link with a generated shortPath,
+// retrying up to retryBudget when the generated shortPath is already taken.
+// Counter IDs never repeat, but may still be taken by a requested shortPath.
+func (u *urlShortner) shortenWithAvailableShortPath(ctx context.Context, idStrategy IDStrategy, link Link, ttl time.Duration) (string, error) {
+	attempts := u.metrics.GetCounter(metrics.ShortPathAttemptsTotal)
+	for attempt := 0; attempt < u.retryBudget; attempt++ {
+		id, err := u.nextAllowedID(ctx, idStrategy, attempt)
+		if err != nil {
+			return "", err
+		}
+		_, aliased, err := u.lookupAlias(ctx, id)
+		if err != nil {
+			return "", NewErrServerError("could not lookup shortpath", err)
+		}
+		shortPath := id
+		if aliased {
+			err = errShortPathTaken
+		} else {
+			shortPath, err = u.doShorten(ctx, id, link, ttl)
+		}
+		collided := err == errShortPathTaken
+		idStrategy.Observe(collided)
+		if collided {
+			attempts.Inc("collision")
+			continue
+		}
+		if err == nil {
+			attempts.Inc("available")
+		}
+		return shortPath, err
+	}
+	return "", NewErrServerError("failed to generate available short_path", nil)
+}
 
-	// FIXME: Get/Exists and Put calls from this file are not atomic.
-	// This can lead to following inconsistent states.
-	// i. existing shortpath gets replaced, both returns success
-	// ii. Same targetURL gets shortened twice with different shortpaths
+// nextAllowedID generates IDs until one passes the filter
+func (u *urlShortner) nextAllowedID(ctx context.Context, idStrategy IDStrategy, attempt int) (string, error) {
+	for i := 0; i < maxFilteredIDs; i++ {
+		id, err := idStrategy.NextID(ctx, attempt)
+		if err != nil {
+			return "", NewErrServerError("could not generate short_path", err)
+		}
+		if u.filter.check(id) == nil {
+			return id, nil
+		}
+	}
+	return "", NewErrServerError("failed to generate allowed short_path", nil)
+}
 
-	err := u.targetURLStore.Put(ctx, shortPath, targetURL)
+// doShorten claims the shortPath and then the reverse mapping of targetURL,
+// both with PutIfAbsent, so concurrent creates can neither replace an existing
+// shortPath nor shorten the same targetURL twice. It returns errShortPathTaken
+// if the shortPath is already claimed, and the winning shortPath if the
+// targetURL got shortened concurrently. Both the mappings expire after ttl,
+// so that the targetURL can be shortened again once the shortPath expires,
+// hence the clicks left behind by an expired shortPath are cleared on claim.
+func (u *urlShortner) doShorten(ctx context.Context, shortPath string, link Link, ttl time.Duration) (string, error) {
+	targetURL := link.TargetURL
+	value, err := encodeLink(link)
+	if err != nil {
+		return "", NewErrServerError("could not encode link", err)
+	}
+	claimed, err := u.targetURLStore.PutIfAbsent(ctx, shortPath, value, ttl)
 	if err != nil {
 		return "", NewErrServerError("could not save shortpath", err)
 	}
-	err = u.shortPathStore.Put(ctx, targetURL, shortPath)
+	if !claimed {
+		return "", errShortPathTaken
+	}
+	// The shortPath may have expired, which leaves its clicks behind
+	if err := u.metrics.GetClickTracker().DeleteClickStats(ctx, shortPath); err != nil {
+		errDelete := u.targetURLStore.Delete(ctx, shortPath)
+		if errDelete != nil {
+			return "", NewErrServerError("could not delete shortpath from store", errDelete)
+		}
+		return "", NewErrServerError("could not delete click stats", err)
+	}
+	claimed, err = u.claimTargetURL(ctx, targetURL, shortPath, ttl)
 	if err != nil {
 		errDelete := u.targetURLStore.Delete(ctx, shortPath)
 		if errDelete != nil {
@@ -107,31 +478,239 @@ func (u *urlShortner) doShorten(ctx context.Context, shortPath string, targetURL
 		}
 		return "", NewErrServerError("could not save targetURL", err)
 	}
-	u.metrics.GetCollector("domain_shortens").Inc(extractDomainFromURL(targetURL))
+	if !claimed {
+		// targetURL got shortened concurrently, hence release the shortPath
+		errDelete := u.targetURLStore.Delete(ctx, shortPath)
+		if errDelete != nil {
+			return "", NewErrServerError("could not delete shortpath from store", errDelete)
+		}
+		existingShortPath, _, found, err := u.lookupShortPath(ctx, targetURL)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", NewErrConflict("targetURL is being shortened concurrently")
+		}
+		return existingShortPath, nil
+	}
+	if collector, err := u.metrics.GetCollector(metrics.DomainShortens); err == nil {
+		collector.Inc(extractDomainFromURL(targetURL))
+	}
+	u.metrics.GetCounter(metrics.ShortensTotal).Inc()
 	return shortPath, nil
+}
+
+// doRetarget writes the reverse mapping of the new targetURL before replacing
+// the forward mapping, so that a failure leaves the old mapping intact.
+// The reverse mapping of the old targetURL is deleted last, it is left stale
+// if that fails or if the shortPath is retargeted concurrently.
+func (u *urlShortner) doRetarget(ctx context.Context, shortPath string, link Link, targetURL string) error {
+	oldTargetURL := link.TargetURL
+	link.TargetURL = targetURL
+	// The new reverse mapping must expire along with the shortPath
+	ttl, err := u.targetURLStore.TTL(ctx, shortPath)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return NewErrNotFound("shortpath mapping not found")
+		}
+		return NewErrServerError("could not lookup shortpath expiry", err)
+	}
+	value, err := u.encodeStoredLink(link, ttl)
+	if err != nil {
+		return NewErrServerError("could not encode link", err)
+	}
+	claimed, err := u.claimTargetURL(ctx, targetURL, shortPath, ttl)
+	if err != nil {
+		return NewErrServerError("could not save targetURL", err)
+	}
+	if !claimed {
+		return NewErrConflict("targetURL is already shortened with different shortpath")
+	}
+	err = u.targetURLStore.Put(ctx, shortPath, value)
+	if err != nil {
+		errDelete := u.shortPathStore.Delete(ctx, targetURL)
+		if errDelete != nil {
+			return NewErrServerError("could not delete targetURL from store", errDelete)
+		}
+		return NewErrServerError("could not save shortpath", err)
+	}
+	// The shortPath is retargeted already, and a stale reverse mapping is
+	// ignored by lookupShortPath and replaced by claimTargetURL, hence the
+	// failure is not returned
+	u.shortPathStore.Delete(ctx, oldTargetURL)
+	return nil
+}
 
+// encodeStoredLink encodes the link read from the store, whose key expires
+// after ttl. Legacy records keep the expiry only as ttl, hence it is copied
+// to ExpiresAt as they are encoded in the current version.
+func (u *urlShortner) encodeStoredLink(link Link, ttl time.Duration) (string, error) {
+	if link.Version == 0 && link.ExpiresAt == nil && ttl > 0 {
+		expiresAt := u.now().Add(ttl).UTC().Truncate(time.Second)
+		link.ExpiresAt = &expiresAt
+	}
+	return encodeLink(link)
 }
 
-func (u *urlShortner) lookupTargetURL(ctx context.Context, shortPath string) (string, bool, error) {
-	targetURL, err := u.targetURLStore.Get(ctx, shortPath)
+// doDelete deletes both the mappings, ttl is used to restore the shortPath
+// if the reverse mapping could not be deleted
+func (u *urlShortner) doDelete(ctx context.Context, shortPath string, link Link, ttl time.Duration) error {
+	value, err := u.encodeStoredLink(link, ttl)
+	if err != nil {
+		return NewErrServerError("could not encode link", err)
+	}
+	err = u.targetURLStore.Delete(ctx, shortPath)
+	if err != nil {
+		return NewErrServerError("could not delete shortpath from store", err)
+	}
+	err = u.shortPathStore.Delete(ctx, link.TargetURL)
+	if err != nil {
+		_, errPut := u.targetURLStore.PutIfAbsent(ctx, shortPath, value, ttl)
+		if errPut != nil {
+			return NewErrServerError("could not restore shortpath in store", errPut)
+		}
+		return NewErrServerError("could not delete targetURL from store", err)
+	}
+	return nil
+}
+
+// getLink returns the key and the Link of shortPath if the shortPath can
+// be resolved
+func (u *urlShortner) getLink(ctx context.Context, shortPath string) (string, Link, error) {
+	key, link, found, err := u.resolveLink(ctx, shortPath)
+	if err != nil {
+		return key, link, NewErrServerError("could not lookup shortpath", err)
+	}
+	if !found {
+		return key, link, NewErrNotFound("shortpath mapping not found")
+	}
+	if link.Disabled {
+		return key, link, NewErrNotFound("shortpath is disabled")
+	}
+	return key, link, nil
+}
+
+// resolveLink looks up the Link of shortPath, and returns the key it is
+// stored by. In case-insensitive mode, the shortPath is looked up as is
+// first, so that mixed-case keys created before the mode was turned on
+// still resolve, then by its canonical lower case, and then by the alias of
+// a mixed-case key.
+func (u *urlShortner) resolveLink(ctx context.Context, shortPath string) (string, Link, bool, error) {
+	link, found, err := u.lookupLink(ctx, shortPath)
+	if err != nil || found || !u.caseInsensitive {
+		return shortPath, link, found, err
+	}
+	canonical := u.canonicalShortPath(shortPath)
+	if canonical != shortPath {
+		link, found, err = u.lookupLink(ctx, canonical)
+		if err != nil || found {
+			return canonical, link, found, err
+		}
+	}
+	key, found, err := u.lookupAlias(ctx, canonical)
+	if err != nil || !found {
+		return shortPath, Link{}, false, err
+	}
+	link, found, err = u.lookupLink(ctx, key)
+	return key, link, found, err
+}
+
+// lookupAlias returns the mixed-case key the lower case shortPath is an
+// alias of, in case-insensitive mode
+func (u *urlShortner) lookupAlias(ctx context.Context, shortPath string) (string, bool, error) {
+	if !u.caseInsensitive {
+		return "", false, nil
+	}
+	key, err := u.aliasStore.Get(ctx, shortPath)
 	if err != nil {
 		if err == store.ErrKeyNotFound {
 			return "", false, nil
 		}
-		return "", false, NewErrServerError("could not lookup shortpath for target URL", err)
+		return "", false, NewErrServerError("could not lookup shortpath alias", err)
+	}
+	// The key may have expired since
+	exists, err := u.targetURLStore.Exists(ctx, key)
+	if err != nil {
+		return "", false, NewErrServerError("could not lookup shortpath", err)
+	}
+	return key, exists, nil
+}
+
+// canonicalShortPath is the key a new shortPath is stored by
+func (u *urlShortner) canonicalShortPath(shortPath string) string {
+	if u.caseInsensitive {
+		return strings.ToLower(shortPath)
+	}
+	return shortPath
+}
+
+func (u *urlShortner) lookupLink(ctx context.Context, shortPath string) (Link, bool, error) {
+	value, err := u.targetURLStore.Get(ctx, shortPath)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return Link{}, false, nil
+		}
+		return Link{}, false, NewErrServerError("could not lookup shortpath for target URL", err)
 	}
-	return targetURL, true, nil
+	link, err := decodeLink(value)
+	if err != nil {
+		return Link{}, false, NewErrServerError("could not decode link", err)
+	}
+	return link, true, nil
 }
 
-func (u *urlShortner) lookupShortPath(ctx context.Context, targetURL string) (string, bool, error) {
+// lookupShortPath returns the shortPath targetURL is shortened with, along
+// with its Link. Stale reverse mappings, whose shortPath got retargeted or
+// deleted, are not found.
+func (u *urlShortner) lookupShortPath(ctx context.Context, targetURL string) (string, Link, bool, error) {
 	shortPath, err := u.shortPathStore.Get(ctx, targetURL)
 	if err != nil {
 		if err == store.ErrKeyNotFound {
-			return "", false, nil
+			return "", Link{}, false, nil
+		}
+		return "", Link{}, false, NewErrServerError("could not lookup shortpath for target URL", err)
+	}
+	link, found, err := u.lookupLink(ctx, shortPath)
+	if err != nil {
+		return "", Link{}, false, err
+	}
+	if !found || link.TargetURL != targetURL {
+		return "", Link{}, false, nil
+	}
+	return shortPath, link, true, nil
+}
+
+// claimTargetURL writes the reverse mapping of targetURL with PutIfAbsent,
+// replacing it with ReplaceIf if it is stale, so that concurrent claims of
+// the same targetURL can not overwrite each other. It returns false if
+// targetURL is shortened with another shortPath.
+func (u *urlShortner) claimTargetURL(ctx context.Context, targetURL string, shortPath string, ttl time.Duration) (bool, error) {
+	for {
+		claimed, err := u.shortPathStore.PutIfAbsent(ctx, targetURL, shortPath, ttl)
+		if err != nil || claimed {
+			return claimed, err
 		}
-		return "", false, NewErrServerError("could not lookup shortpath for target URL", err)
+		existing, err := u.shortPathStore.Get(ctx, targetURL)
+		if err == store.ErrKeyNotFound {
+			// The mapping got deleted in the meantime, hence claim it again
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		link, found, err := u.lookupLink(ctx, existing)
+		if err != nil {
+			return false, err
+		}
+		if found && link.TargetURL == targetURL {
+			return false, nil
+		}
+		replaced, err := u.shortPathStore.ReplaceIf(ctx, targetURL, existing, shortPath, ttl)
+		if err != nil || replaced {
+			return replaced, err
+		}
+		// The mapping changed in the meantime, hence check it again
 	}
-	return shortPath, true, nil
 }
 
 func validateTargetURL(targetURL string) error {
@@ -155,9 +734,6 @@ func validateShortPath(shortPath string) error {
 	if !isValidPathSegment(shortPath) {
 		return NewErrValidation("short_path contains disallowed characters")
 	}
-	if shortPath == "metrics" {
-		return NewErrValidation("short_path is reserved")
-	}
 	if len(shortPath) > 50 {
 		return NewErrValidation("short_path is too long")
 	}
@@ -0,0 +1,240 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	appmetrics "github.com/thenilesh/url-shortner/metrics"
+	"github.com/thenilesh/url-shortner/mocks"
+	"github.com/thenilesh/url-shortner/store"
+)
+
+const concurrentCreates = 50
+
+func newConcurrencyTestStores(t *testing.T) map[string][2]store.KVStore {
+	mr := miniredis.RunT(t)
+	client, _ := store.NewRedisClient(mr.Addr(), "", 0)
+	redisTargetURLStore, _ := store.NewRedisKVStore(client, "target")
+	redisShortPathStore, _ := store.NewRedisKVStore(client, "short")
+	return map[string][2]store.KVStore{
+		"gomap": {store.NewGoMapStore(), store.NewGoMapStore()},
+		"redis": {redisTargetURLStore, redisShortPathStore},
+	}
+}
+
+func newConcurrencyTestShortner(t *testing.T, targetURLStore store.KVStore, shortPathStore store.KVStore) URLShortner {
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+	assert.NoError(t, err)
+	return shortner
+}
+
+func TestURLShortner_CreateShortPath_concurrentSameShortPath(t *testing.T) {
+	for name, stores := range newConcurrencyTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			shortner := newConcurrencyTestShortner(t, stores[0], stores[1])
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			winners := make([]string, 0)
+			conflicts := 0
+			for i := 0; i < concurrentCreates; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					targetURL := fmt.Sprintf("https://example.com/%d", i)
+					_, err := shortner.CreateShortPath(ctx, "contended", targetURL, CreateOptions{})
+					mu.Lock()
+					defer mu.Unlock()
+					if err == nil {
+						winners = append(winners, targetURL)
+						return
+					}
+					if _, ok := err.(*ErrConflict); ok {
+						conflicts++
+						return
+					}
+					t.Errorf("unexpected error: %v", err)
+				}(i)
+			}
+			wg.Wait()
+
+			assert.Len(t, winners, 1)
+			assert.Equal(t, concurrentCreates-1, conflicts)
+			targetURL, err := shortner.GetTargetURL(ctx, "contended")
+			assert.NoError(t, err)
+			assert.Equal(t, winners[0], targetURL)
+			shortPath, err := stores[1].Get(ctx, winners[0])
+			assert.NoError(t, err)
+			assert.Equal(t, "contended", shortPath)
+		})
+	}
+}
+
+func TestURLShortner_CreateShortPath_concurrentSameTargetURL(t *testing.T) {
+	for name, stores := range newConcurrencyTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			shortner := newConcurrencyTestShortner(t, stores[0], stores[1])
+			targetURL := "https://example.com/popular"
+
+			var wg sync.WaitGroup
+			shortPaths := make([]string, concurrentCreates)
+			for i := 0; i < concurrentCreates; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					shortPath, err := shortner.CreateShortPath(ctx, "", targetURL, CreateOptions{})
+					assert.NoError(t, err)
+					shortPaths[i] = shortPath
+				}(i)
+			}
+			wg.Wait()
+
+			for _, shortPath := range shortPaths {
+				assert.Equal(t, shortPaths[0], shortPath)
+			}
+			got, err := shortner.GetTargetURL(ctx, shortPaths[0])
+			assert.NoError(t, err)
+			assert.Equal(t, targetURL, got)
+		})
+	}
+}
+
+func TestURLShortner_CreateShortPath_concurrentStaleTargetURL(t *testing.T) {
+	for name, stores := range newConcurrencyTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			shortner := newConcurrencyTestShortner(t, stores[0], stores[1])
+			targetURL := "https://example.com/popular"
+			// The shortPath of the reverse mapping got deleted
+			assert.NoError(t, stores[1].Put(ctx, targetURL, "deleted"))
+
+			var wg sync.WaitGroup
+			shortPaths := make([]string, concurrentCreates)
+			for i := 0; i < concurrentCreates; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					shortPath, err := shortner.CreateShortPath(ctx, "", targetURL, CreateOptions{})
+					assert.NoError(t, err)
+					shortPaths[i] = shortPath
+				}(i)
+			}
+			wg.Wait()
+
+			for _, shortPath := range shortPaths {
+				assert.Equal(t, shortPaths[0], shortPath)
+			}
+			shortPath, err := stores[1].Get(ctx, targetURL)
+			assert.NoError(t, err)
+			assert.Equal(t, shortPaths[0], shortPath)
+			// The losers released their shortPaths
+			keys, _, err := stores[0].Scan(ctx, "", 2*concurrentCreates)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{shortPaths[0]}, keys)
+		})
+	}
+}
+
+func TestURLShortner_CreateShortPath_concurrentCounterIDs(t *testing.T) {
+	for name, stores := range newConcurrencyTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			metrics := new(mocks.Metrics)
+			collector := new(mocks.Collector)
+			collector.On("Inc", mock.Anything)
+			metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+			clickTracker := new(mocks.ClickTracker)
+			clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+			metrics.On("GetClickTracker").Return(clickTracker)
+			metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+			metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+			// Only 9 strings of length 2, the rest must grow in length
+			shortner, err := NewURLShortnerBuilder().
+				SetTargetURLStore(stores[0]).
+				SetShortPathStore(stores[1]).
+				SetMetrics(metrics).
+				SetCharset("abc").
+				SetMinLength(2).
+				SetCounterIDs(store.NewGoMapSequence(), true).
+				Build()
+			assert.NoError(t, err)
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			shortPaths := make(map[string]bool)
+			for i := 0; i < concurrentCreates; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					targetURL := fmt.Sprintf("https://example.com/%d", i)
+					shortPath, err := shortner.CreateShortPath(ctx, "", targetURL, CreateOptions{})
+					assert.NoError(t, err)
+					mu.Lock()
+					defer mu.Unlock()
+					assert.False(t, shortPaths[shortPath], "%s generated twice", shortPath)
+					shortPaths[shortPath] = true
+				}(i)
+			}
+			wg.Wait()
+			assert.Len(t, shortPaths, concurrentCreates)
+		})
+	}
+}
+
+// TestURLShortner_CreateShortPath_saturatedKeyspace fills the keyspace of
+// shortest shortPaths, creates must keep succeeding with longer shortPaths
+func TestURLShortner_CreateShortPath_saturatedKeyspace(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	attempts := appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result")
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(attempts)
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(store.NewGoMapStore()).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetMetrics(metrics).
+		SetCharset("ab").
+		SetMinLength(1).
+		SetMaxLength(12).
+		SetRetryBudget(4).
+		Build()
+	assert.NoError(t, err)
+	// Seeded, so that collisions and hence the outcome don't vary by run
+	strategy := shortner.(*urlShortner).idStrategies[StyleRandom].(*randomIDStrategy)
+	strategy.randomStrGen = &randomStrGen{minLength: 1, maxLength: 12, charset: "ab", rand: rand.New(rand.NewSource(1))}
+
+	for i := 0; i < 200; i++ {
+		_, err := shortner.CreateShortPath(ctx, "", fmt.Sprintf("https://example.com/%d", i), CreateOptions{})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, float64(200), attempts.Value("available"))
+	assert.Greater(t, attempts.Value("collision"), float64(0))
+	assert.Greater(t, strategy.minLength, 1)
+}
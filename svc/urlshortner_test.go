@@ -5,14 +5,25 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	appmetrics "github.com/thenilesh/url-shortner/metrics"
 	"github.com/thenilesh/url-shortner/store"
 
 	"github.com/thenilesh/url-shortner/mocks"
 )
 
+// linkTo matches encoded Link pointing to targetURL
+func linkTo(targetURL string) interface{} {
+	return mock.MatchedBy(func(value string) bool {
+		link, err := decodeLink(value)
+		return err == nil && link.Version == linkVersion && link.TargetURL == targetURL
+	})
+}
+
 func TestURLShortner_CreateShortPath(t *testing.T) {
 	ctx := context.Background()
 
@@ -30,13 +41,17 @@ func TestURLShortner_CreateShortPath(t *testing.T) {
 	shortPathExpected := "abc123"
 	targetURLExpected := "https://www.google.com"
 	targetURLStore.On("Get", ctx, shortPathExpected).Return("", store.ErrKeyNotFound)
-	targetURLStore.On("Put", ctx, shortPathExpected, targetURLExpected).Return(nil)
+	targetURLStore.On("PutIfAbsent", ctx, shortPathExpected, linkTo(targetURLExpected), time.Duration(0)).Return(true, nil)
 	shortPathStore.On("Get", ctx, targetURLExpected).Return("", store.ErrKeyNotFound)
-	shortPathStore.On("Put", ctx, targetURLExpected, shortPathExpected).Return(nil)
+	shortPathStore.On("PutIfAbsent", ctx, targetURLExpected, shortPathExpected, time.Duration(0)).Return(true, nil)
 	collector.On("Inc", mock.Anything).Once()
-	metrics.On("GetCollector", "domain_shortens").Return(collector)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
 
-	shortPath, err := shortner.CreateShortPath(ctx, shortPathExpected, targetURLExpected)
+	shortPath, err := shortner.CreateShortPath(ctx, shortPathExpected, targetURLExpected, CreateOptions{})
 	assert.NoError(t, err)
 	assert.Equal(t, shortPathExpected, shortPath)
 
@@ -67,14 +82,18 @@ func TestURLShortner_CreateShortPath_random(t *testing.T) {
 
 	emptyShortPath := "" // auto generate
 	targetURLExpected := "https://www.example.com/somerajsjf"
-	targetURLStore.On("Exists", ctx, mock.Anything).Return(false, nil)
-	targetURLStore.On("Put", ctx, mock.Anything, targetURLExpected).Return(nil)
+	targetURLStore.On("PutIfAbsent", ctx, mock.Anything, linkTo(targetURLExpected), time.Duration(0)).Return(true, nil)
 	shortPathStore.On("Get", ctx, targetURLExpected).Return("", store.ErrKeyNotFound)
-	shortPathStore.On("Put", ctx, targetURLExpected, mock.Anything).Return(nil)
+	shortPathStore.On("PutIfAbsent", ctx, targetURLExpected, mock.Anything, time.Duration(0)).Return(true, nil)
 	collector.On("Inc", mock.Anything).Once()
-	metrics.On("GetCollector", "domain_shortens").Return(collector)
-
-	shortPath, err := shortner.CreateShortPath(ctx, emptyShortPath, targetURLExpected)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+
+	shortPath, err := shortner.CreateShortPath(ctx, emptyShortPath, targetURLExpected, CreateOptions{})
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, len(shortPath), expMinLen)
 	assert.LessOrEqual(t, len(shortPath), expMaxLen)
@@ -105,13 +124,17 @@ func TestURLShortner_CreateShortPath_http(t *testing.T) {
 	targetURLExpected := "http://www.google.com/"
 	targetURL := "http://www.google.com"
 	targetURLStore.On("Get", ctx, shortPathExpected).Return("", store.ErrKeyNotFound)
-	targetURLStore.On("Put", ctx, shortPathExpected, targetURL).Return(nil)
+	targetURLStore.On("PutIfAbsent", ctx, shortPathExpected, linkTo(targetURL), time.Duration(0)).Return(true, nil)
 	shortPathStore.On("Get", ctx, targetURL).Return("", store.ErrKeyNotFound)
-	shortPathStore.On("Put", ctx, targetURL, shortPathExpected).Return(nil)
+	shortPathStore.On("PutIfAbsent", ctx, targetURL, shortPathExpected, time.Duration(0)).Return(true, nil)
 	collector.On("Inc", mock.Anything).Once()
-	metrics.On("GetCollector", "domain_shortens").Return(collector)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
 
-	shortPath, err := shortner.CreateShortPath(ctx, shortPathExpected, targetURLExpected)
+	shortPath, err := shortner.CreateShortPath(ctx, shortPathExpected, targetURLExpected, CreateOptions{})
 	assert.NoError(t, err)
 	assert.Equal(t, shortPathExpected, shortPath)
 
@@ -135,70 +158,70 @@ func TestURLShortner_CreateShortPath_invalid_path_url(t *testing.T) {
 
 	targetURLExpected := "https://www.google.com"
 
-	shortPath, err := shortner.CreateShortPath(ctx, "abc/123", targetURLExpected)
+	shortPath, err := shortner.CreateShortPath(ctx, "abc/123", targetURLExpected, CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok := err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "short_path contains disallowed characters")
 
-	shortPath, err = shortner.CreateShortPath(ctx, "sp ", targetURLExpected)
+	shortPath, err = shortner.CreateShortPath(ctx, "sp ", targetURLExpected, CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "short_path contains leading or trailing spaces")
 
-	shortPath, err = shortner.CreateShortPath(ctx, " sp", targetURLExpected)
+	shortPath, err = shortner.CreateShortPath(ctx, " sp", targetURLExpected, CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "short_path contains leading or trailing spaces")
 
-	shortPath, err = shortner.CreateShortPath(ctx, "metrics", targetURLExpected)
+	shortPath, err = shortner.CreateShortPath(ctx, "metrics", targetURLExpected, CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "short_path is reserved")
 
-	shortPath, err = shortner.CreateShortPath(ctx, strings.Repeat("a", 51), targetURLExpected)
+	shortPath, err = shortner.CreateShortPath(ctx, strings.Repeat("a", 51), targetURLExpected, CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "short_path is too long")
 
-	shortPath, err = shortner.CreateShortPath(ctx, "@", targetURLExpected)
+	shortPath, err = shortner.CreateShortPath(ctx, "@", targetURLExpected, CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 
 	expectedShortPath := "my-short-path"
-	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, "http://www.google.com ")
+	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, "http://www.google.com ", CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "target_url contains leading or trailing spaces")
 
-	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, " http://www.google.com")
+	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, " http://www.google.com", CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "target_url contains leading or trailing spaces")
 
-	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, "://www.google.com")
+	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, "://www.google.com", CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
 	assert.True(t, ok, "Expected error of type ErrValidation")
 	assert.EqualError(t, err, "target_url is not valid")
 
-	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, "ftp://hello")
+	shortPath, err = shortner.CreateShortPath(ctx, expectedShortPath, "ftp://hello", CreateOptions{})
 	assert.Equal(t, "", shortPath)
 	assert.Error(t, err, "Expected an error")
 	_, ok = err.(*ErrValidation)
@@ -247,3 +270,700 @@ func TestURLShortner_GetTargetURL(t *testing.T) {
 	shortPathStore.AssertExpectations(t)
 	metrics.AssertExpectations(t)
 }
+
+func TestURLShortner_UpdateTargetURL(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+
+	oldTargetURL := "https://www.google.com"
+	newTargetURL := "https://www.google.co.in"
+	targetURLStore.On("Get", ctx, "abc123").Return(oldTargetURL, nil)
+	shortPathStore.On("Get", ctx, newTargetURL).Return("", store.ErrKeyNotFound)
+	targetURLStore.On("TTL", ctx, "abc123").Return(time.Duration(0), nil)
+	shortPathStore.On("PutIfAbsent", ctx, newTargetURL, "abc123", time.Duration(0)).Return(true, nil)
+	targetURLStore.On("Put", ctx, "abc123", linkTo(newTargetURL)).Return(nil)
+	shortPathStore.On("Delete", ctx, oldTargetURL).Return(nil)
+
+	err := shortner.UpdateTargetURL(ctx, "abc123", newTargetURL+"/")
+	assert.NoError(t, err)
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_UpdateTargetURL_legacyExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+	now := time.Date(2023, 10, 1, 10, 0, 0, 0, time.UTC)
+	shortner.(*urlShortner).now = func() time.Time { return now }
+
+	// Legacy record keeps the expiry only as TTL of the store
+	oldTargetURL := "https://www.google.com"
+	newTargetURL := "https://www.google.co.in"
+	targetURLStore.On("Get", ctx, "abc123").Return(oldTargetURL, nil)
+	shortPathStore.On("Get", ctx, newTargetURL).Return("", store.ErrKeyNotFound)
+	targetURLStore.On("TTL", ctx, "abc123").Return(time.Hour, nil)
+	shortPathStore.On("PutIfAbsent", ctx, newTargetURL, "abc123", time.Hour).Return(true, nil)
+	targetURLStore.On("Put", ctx, "abc123", mock.MatchedBy(func(value string) bool {
+		link, err := decodeLink(value)
+		return err == nil && link.Version == linkVersion && link.ExpiresAt != nil && link.ExpiresAt.Equal(now.Add(time.Hour))
+	})).Return(nil)
+	shortPathStore.On("Delete", ctx, oldTargetURL).Return(nil)
+
+	err := shortner.UpdateTargetURL(ctx, "abc123", newTargetURL)
+	assert.NoError(t, err)
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_UpdateTargetURL_errors(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+
+	targetURLStore.On("Get", ctx, "unknown_shortpath").Return("", store.ErrKeyNotFound)
+	targetURLStore.On("Get", ctx, "abc123").Return("https://www.google.com", nil)
+	shortPathStore.On("Get", ctx, "https://www.example.com").Return("xyz", nil)
+	targetURLStore.On("Get", ctx, "xyz").Return("https://www.example.com", nil)
+
+	err := shortner.UpdateTargetURL(ctx, "abc123", "ftp://hello")
+	_, ok := err.(*ErrValidation)
+	assert.True(t, ok, "Expected error of type ErrValidation")
+
+	err = shortner.UpdateTargetURL(ctx, "unknown_shortpath", "https://www.example.com")
+	_, ok = err.(*ErrNotFound)
+	assert.True(t, ok, "Expected error of type ErrNotFound")
+
+	err = shortner.UpdateTargetURL(ctx, "abc123", "https://www.google.com")
+	assert.NoError(t, err)
+
+	err = shortner.UpdateTargetURL(ctx, "abc123", "https://www.example.com")
+	_, ok = err.(*ErrConflict)
+	assert.True(t, ok, "Expected error of type ErrConflict")
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_staleReverseMapping(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+	shortPathStore := store.NewGoMapStore()
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(store.NewGoMapStore()).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+	assert.NoError(t, err)
+	_, err = shortner.CreateShortPath(ctx, "x1", "https://old.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, shortner.UpdateTargetURL(ctx, "x1", "https://new.com"))
+	// As left by a failed delete or a concurrent retarget
+	_, err = shortPathStore.PutIfAbsent(ctx, "https://old.com", "x1", 0)
+	assert.NoError(t, err)
+
+	shortPath, err := shortner.CreateShortPath(ctx, "", "https://old.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, "x1", shortPath)
+	targetURL, err := shortner.GetTargetURL(ctx, shortPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://old.com", targetURL)
+	targetURL, err = shortner.GetTargetURL(ctx, "x1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://new.com", targetURL)
+
+	// Retargeting to a targetURL with stale reverse mapping replaces it too
+	_, err = shortPathStore.PutIfAbsent(ctx, "https://other.com", "x1", 0)
+	assert.NoError(t, err)
+	_, err = shortner.CreateShortPath(ctx, "x2", "https://two.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, shortner.UpdateTargetURL(ctx, "x2", "https://other.com"))
+	reverse, err := shortPathStore.Get(ctx, "https://other.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "x2", reverse)
+}
+
+func TestURLShortner_DeleteShortPath(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+
+	targetURLStore.On("Get", ctx, "abc123").Return("https://www.google.com", nil)
+	targetURLStore.On("TTL", ctx, "abc123").Return(time.Duration(0), nil)
+	targetURLStore.On("Delete", ctx, "abc123").Return(nil)
+	shortPathStore.On("Delete", ctx, "https://www.google.com").Return(nil)
+	targetURLStore.On("Get", ctx, "unknown_shortpath").Return("", store.ErrKeyNotFound)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", ctx, "abc123").Return(nil).Once()
+	metrics.On("GetClickTracker").Return(clickTracker)
+
+	err := shortner.DeleteShortPath(ctx, "abc123")
+	assert.NoError(t, err)
+
+	err = shortner.DeleteShortPath(ctx, "unknown_shortpath")
+	_, ok := err.(*ErrNotFound)
+	assert.True(t, ok, "Expected error of type ErrNotFound")
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+	clickTracker.AssertExpectations(t)
+}
+
+func TestURLShortner_DeleteShortPath_compensation(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+
+	targetURLStore.On("Get", ctx, "abc123").Return("https://www.google.com", nil)
+	targetURLStore.On("TTL", ctx, "abc123").Return(time.Hour, nil)
+	targetURLStore.On("Delete", ctx, "abc123").Return(nil)
+	shortPathStore.On("Delete", ctx, "https://www.google.com").Return(errors.New("connection error"))
+	targetURLStore.On("PutIfAbsent", ctx, "abc123", linkTo("https://www.google.com"), time.Hour).Return(true, nil)
+
+	err := shortner.DeleteShortPath(ctx, "abc123")
+	_, ok := err.(*ErrServerError)
+	assert.True(t, ok, "Expected error of type ErrServerError")
+	assert.EqualError(t, err, "could not delete targetURL from store")
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_CreateShortPath_expiry(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+	now := time.Now()
+	shortner.(*urlShortner).now = func() time.Time { return now }
+
+	targetURL := "https://www.google.com"
+	targetURLStore.On("Get", ctx, "campaign").Return("", store.ErrKeyNotFound)
+	shortPathStore.On("Get", ctx, targetURL).Return("", store.ErrKeyNotFound)
+	targetURLStore.On("PutIfAbsent", ctx, "campaign", linkTo(targetURL), time.Hour).Return(true, nil)
+	shortPathStore.On("PutIfAbsent", ctx, targetURL, "campaign", time.Hour).Return(true, nil)
+	collector.On("Inc", mock.Anything).Once()
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+
+	shortPath, err := shortner.CreateShortPath(ctx, "campaign", targetURL, CreateOptions{ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Equal(t, "campaign", shortPath)
+
+	_, err = shortner.CreateShortPath(ctx, "campaign", targetURL, CreateOptions{ExpiresAt: now.Add(-time.Second)})
+	_, ok := err.(*ErrValidation)
+	assert.True(t, ok, "Expected error of type ErrValidation")
+	assert.EqualError(t, err, "expires_at is in the past")
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_CreateShortPath_differentExpiry(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(store.NewGoMapStore()).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetMetrics(metrics).
+		Build()
+	assert.NoError(t, err)
+	expiresAt := time.Now().Add(time.Hour)
+	_, err = shortner.CreateShortPath(ctx, "campaign", "https://campaign.com", CreateOptions{ExpiresAt: expiresAt})
+	assert.NoError(t, err)
+	_, err = shortner.CreateShortPath(ctx, "home", "https://home.com", CreateOptions{})
+	assert.NoError(t, err)
+
+	// Same expiry is idempotent
+	shortPath, err := shortner.CreateShortPath(ctx, "campaign", "https://campaign.com", CreateOptions{ExpiresAt: expiresAt})
+	assert.NoError(t, err)
+	assert.Equal(t, "campaign", shortPath)
+	shortPath, err = shortner.CreateShortPath(ctx, "", "https://home.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "home", shortPath)
+
+	tests := []struct {
+		name      string
+		shortPath string
+		targetURL string
+		expiresAt time.Time
+		err       string
+	}{
+		{"shortPath never expiring", "campaign", "https://campaign.com", time.Time{}, "shortpath already exists with different expiry"},
+		{"shortPath expiring later", "campaign", "https://campaign.com", expiresAt.Add(time.Hour), "shortpath already exists with different expiry"},
+		{"targetURL never expiring", "", "https://campaign.com", time.Time{}, "targetURL is already shortened with different expiry"},
+		{"targetURL expiring", "", "https://home.com", expiresAt, "targetURL is already shortened with different expiry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := shortner.CreateShortPath(ctx, tt.shortPath, tt.targetURL, CreateOptions{ExpiresAt: tt.expiresAt})
+			assert.IsType(t, &ErrConflict{}, err)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestURLShortner_expiredShortPathCanBeRecreated(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client, _ := store.NewRedisClient(mr.Addr(), "", 0)
+	targetURLStore, _ := store.NewRedisKVStore(client, "target")
+	shortPathStore, _ := store.NewRedisKVStore(client, "short")
+	shortner := newConcurrencyTestShortner(t, targetURLStore, shortPathStore)
+
+	targetURL := "https://www.google.com"
+	shortPath, err := shortner.CreateShortPath(ctx, "", targetURL, CreateOptions{ExpiresAt: time.Now().Add(time.Minute)})
+	assert.NoError(t, err)
+
+	mr.FastForward(time.Minute)
+	_, err = shortner.GetTargetURL(ctx, shortPath)
+	_, ok := err.(*ErrNotFound)
+	assert.True(t, ok, "Expected error of type ErrNotFound")
+
+	_, err = shortner.CreateShortPath(ctx, "campaign", targetURL, CreateOptions{})
+	assert.NoError(t, err)
+	got, err := shortner.GetTargetURL(ctx, "campaign")
+	assert.NoError(t, err)
+	assert.Equal(t, targetURL, got)
+}
+
+func TestURLShortner_GetShortURL(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("GetClickStats", ctx, "abc123").Return(appmetrics.ClickStats{Total: 7}, nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+	now := time.Date(2023, 10, 1, 10, 0, 0, 0, time.UTC)
+	shortner.(*urlShortner).now = func() time.Time { return now }
+
+	targetURLStore.On("Get", ctx, "abc123").Return("https://www.google.com", nil)
+	targetURLStore.On("TTL", ctx, "abc123").Return(time.Hour, nil)
+	targetURLStore.On("Get", ctx, "unknown_shortpath").Return("", store.ErrKeyNotFound)
+
+	shortURL, err := shortner.GetShortURL(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, &ShortURL{
+		ShortPath: "abc123",
+		TargetURL: "https://www.google.com",
+		ExpiresAt: now.Add(time.Hour),
+		Clicks:    7,
+	}, shortURL)
+
+	_, err = shortner.GetShortURL(ctx, "unknown_shortpath")
+	_, ok := err.(*ErrNotFound)
+	assert.True(t, ok, "Expected error of type ErrNotFound")
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_GetShortURL_record(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("GetClickStats", ctx, "abc123").Return(appmetrics.ClickStats{Total: 7}, nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+
+	targetURLStore.On("Get", ctx, "abc123").Return(`{"v":1,"target_url":"https://www.google.com",`+
+		`"created_at":"2023-10-01T10:00:00Z","expires_at":"2023-10-02T10:00:00Z","tags":["campaign"]}`, nil)
+	targetURLStore.On("Get", ctx, "disabled").Return(`{"v":1,"target_url":"https://www.google.com","disabled":true}`, nil)
+
+	shortURL, err := shortner.GetShortURL(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, &ShortURL{
+		ShortPath: "abc123",
+		TargetURL: "https://www.google.com",
+		CreatedAt: time.Date(2023, 10, 1, 10, 0, 0, 0, time.UTC),
+		ExpiresAt: time.Date(2023, 10, 2, 10, 0, 0, 0, time.UTC),
+		Tags:      []string{"campaign"},
+		Clicks:    7,
+	}, shortURL)
+
+	_, err = shortner.GetTargetURL(ctx, "disabled")
+	_, ok := err.(*ErrNotFound)
+	assert.True(t, ok, "Expected error of type ErrNotFound")
+	assert.EqualError(t, err, "shortpath is disabled")
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_CreateShortPath_style(t *testing.T) {
+	ctx := context.Background()
+
+	targetURLStore := new(mocks.KVStore)
+	shortPathStore := new(mocks.KVStore)
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+
+	shortner, _ := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+
+	targetURL := "https://www.example.com/words"
+	targetURLStore.On("PutIfAbsent", ctx, mock.Anything, linkTo(targetURL), time.Duration(0)).Return(true, nil)
+	shortPathStore.On("Get", ctx, targetURL).Return("", store.ErrKeyNotFound)
+	shortPathStore.On("PutIfAbsent", ctx, targetURL, mock.Anything, time.Duration(0)).Return(true, nil)
+	collector.On("Inc", mock.Anything).Once()
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+
+	shortPath, err := shortner.CreateShortPath(ctx, "", targetURL, CreateOptions{Style: StyleWords})
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[a-z]+-[a-z]+-[0-9]{2}$`, shortPath)
+
+	_, err = shortner.CreateShortPath(ctx, "", targetURL, CreateOptions{Style: "emoji"})
+	assert.IsType(t, &ErrValidation{}, err)
+	assert.EqualError(t, err, "unknown style emoji")
+
+	_, err = shortner.CreateShortPath(ctx, "abc123", targetURL, CreateOptions{Style: StyleWords})
+	assert.IsType(t, &ErrValidation{}, err)
+	assert.EqualError(t, err, "style can not be set along with short_path")
+
+	// Counter style is available only with counter IDs
+	_, err = shortner.CreateShortPath(ctx, "", targetURL, CreateOptions{Style: StyleCounter})
+	assert.IsType(t, &ErrValidation{}, err)
+
+	targetURLStore.AssertExpectations(t)
+	shortPathStore.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestURLShortner_caseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", ctx, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	targetURLStore := store.NewGoMapStore()
+	shortPathStore := store.NewGoMapStore()
+	legacy, err := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetMetrics(metrics).
+		Build()
+	assert.NoError(t, err)
+	_, err = legacy.CreateShortPath(ctx, "Legacy", "https://example.com/legacy", CreateOptions{})
+	assert.NoError(t, err)
+	_, err = legacy.CreateShortPath(ctx, "AbC", "https://a.com", CreateOptions{})
+	assert.NoError(t, err)
+
+	aliasStore := store.NewGoMapStore()
+	added, err := IndexAliases(ctx, targetURLStore, aliasStore)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(shortPathStore).
+		SetAliasStore(aliasStore).
+		SetMetrics(metrics).
+		SetCaseInsensitive(true).
+		Build()
+	assert.NoError(t, err)
+
+	// Mixed-case keys stored earlier resolve in any case
+	for _, spelling := range []string{"Legacy", "legacy", "LEGACY"} {
+		targetURL, err := shortner.GetTargetURL(ctx, spelling)
+		assert.NoError(t, err, spelling)
+		assert.Equal(t, "https://example.com/legacy", targetURL, spelling)
+	}
+
+	// and can't be taken in another case
+	_, err = shortner.CreateShortPath(ctx, "ABC", "https://b.com", CreateOptions{})
+	assert.IsType(t, &ErrConflict{}, err)
+	shortPath, err := shortner.CreateShortPath(ctx, "abc", "https://a.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "AbC", shortPath)
+	exists, err := targetURLStore.Exists(ctx, "abc")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// Deleting it releases the alias
+	assert.NoError(t, shortner.DeleteShortPath(ctx, "abc"))
+	_, err = aliasStore.Get(ctx, "abc")
+	assert.Equal(t, store.ErrKeyNotFound, err)
+	shortPath, err = shortner.CreateShortPath(ctx, "ABC", "https://b.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", shortPath)
+
+	// New shortPaths are stored in lower case, and resolve in any case
+	shortPath, err = shortner.CreateShortPath(ctx, "MyLink", "https://example.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "mylink", shortPath)
+	for _, spelling := range []string{"mylink", "MYLINK", "myLink"} {
+		targetURL, err := shortner.GetTargetURL(ctx, spelling)
+		assert.NoError(t, err, spelling)
+		assert.Equal(t, "https://example.com", targetURL, spelling)
+	}
+
+	// Clicks are tracked under the key, whatever the case requested
+	key, targetURL, err := shortner.ResolveShortPath(ctx, "MYLINK")
+	assert.NoError(t, err)
+	assert.Equal(t, "mylink", key)
+	assert.Equal(t, "https://example.com", targetURL)
+	key, _, err = shortner.ResolveShortPath(ctx, "LEGACY")
+	assert.NoError(t, err)
+	assert.Equal(t, "Legacy", key)
+
+	// Creating it again in another case is idempotent, but not for another targetURL
+	shortPath, err = shortner.CreateShortPath(ctx, "MYLINK", "https://example.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "mylink", shortPath)
+	_, err = shortner.CreateShortPath(ctx, "MYLINK", "https://example.org", CreateOptions{})
+	assert.IsType(t, &ErrConflict{}, err)
+
+	// Updates and deletes find the stored key
+	assert.NoError(t, shortner.UpdateTargetURL(ctx, "MyLink", "https://example.net"))
+	targetURL, err = shortner.GetTargetURL(ctx, "mylink")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.net", targetURL)
+	assert.NoError(t, shortner.DeleteShortPath(ctx, "MYLINK"))
+	_, err = shortner.GetTargetURL(ctx, "mylink")
+	assert.IsType(t, &ErrNotFound{}, err)
+}
+
+func TestURLShortner_caseInsensitive_generatedAlias(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	metrics.On("GetCounter", appmetrics.ShortPathAttemptsTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortPathAttemptsTotal, "", "result"))
+	targetURLStore := store.NewGoMapStore()
+	aliasStore := store.NewGoMapStore()
+	link, _ := encodeLink(Link{TargetURL: "https://example.com/legacy"})
+	targetURLStore.PutIfAbsent(ctx, "A", link, 0)
+	_, err := IndexAliases(ctx, targetURLStore, aliasStore)
+	assert.NoError(t, err)
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetAliasStore(aliasStore).
+		SetMetrics(metrics).
+		SetMinLength(1).
+		SetMaxLength(1).
+		SetCharset("ab").
+		SetRetryBudget(20).
+		SetSecureRandom(false).
+		SetCaseInsensitive(true).
+		Build()
+	assert.NoError(t, err)
+
+	// a is the alias of A, hence b is the only one available
+	shortPath, err := shortner.CreateShortPath(ctx, "", "https://example.com", CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", shortPath)
+}
+
+func TestURLShortner_ListShortURLs(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", ctx, mock.Anything).Return(nil)
+	clickTracker.On("GetClickStats", ctx, mock.Anything).Return(appmetrics.ClickStats{Total: 7}, nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	targetURLStore := store.NewGoMapStore()
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetMetrics(metrics).
+		Build()
+	assert.NoError(t, err)
+	links := map[string]string{
+		"docs":    "https://docs.example.com/start",
+		"blog":    "https://blog.example.org",
+		"release": "https://github.com/example/releases",
+		"example": "https://other.net",
+	}
+	for shortPath, targetURL := range links {
+		_, err := shortner.CreateShortPath(ctx, shortPath, targetURL, CreateOptions{})
+		assert.NoError(t, err)
+	}
+	disabled, _ := encodeLink(Link{TargetURL: "https://example.com/disabled", Disabled: true})
+	assert.NoError(t, targetURLStore.Put(ctx, "disabled", disabled))
+
+	// Pages through all but the disabled one
+	listed := make(map[string]string)
+	opts := ListOptions{Limit: 3}
+	for {
+		page, err := shortner.ListShortURLs(ctx, opts)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.ShortURLs), 3)
+		for _, shortURL := range page.ShortURLs {
+			listed[shortURL.ShortPath] = shortURL.TargetURL
+			assert.Equal(t, int64(7), shortURL.Clicks)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	assert.Equal(t, links, listed)
+
+	// Query matches shortPath or domain of targetURL
+	page, err := shortner.ListShortURLs(ctx, ListOptions{Limit: 10, Query: "EXAMPLE"})
+	assert.NoError(t, err)
+	shortPaths := make([]string, 0)
+	for _, shortURL := range page.ShortURLs {
+		shortPaths = append(shortPaths, shortURL.ShortPath)
+	}
+	assert.ElementsMatch(t, []string{"docs", "blog", "example"}, shortPaths)
+	assert.Equal(t, "", page.NextCursor)
+
+	_, err = shortner.ListShortURLs(ctx, ListOptions{Limit: 0})
+	assert.IsType(t, &ErrValidation{}, err)
+}
+
+func TestURLShortner_CreateShortPath_dryRun(t *testing.T) {
+	ctx := context.Background()
+	metrics := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	metrics.On("GetCollector", "domain_shortens").Return(collector, nil)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	metrics.On("GetClickTracker").Return(clickTracker)
+	metrics.On("GetCounter", appmetrics.ShortensTotal).Return(appmetrics.NewCounterVec(appmetrics.ShortensTotal, ""))
+	targetURLStore := store.NewGoMapStore()
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(targetURLStore).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetMetrics(metrics).
+		Build()
+	assert.NoError(t, err)
+	_, err = shortner.CreateShortPath(ctx, "taken", "https://example.com", CreateOptions{})
+	assert.NoError(t, err)
+
+	shortPath, err := shortner.CreateShortPath(ctx, "new", "https://example.org", CreateOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "new", shortPath)
+	shortPath, err = shortner.CreateShortPath(ctx, "", "https://example.org", CreateOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "", shortPath)
+	exists, err := targetURLStore.Exists(ctx, "new")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = shortner.CreateShortPath(ctx, "taken", "https://example.org", CreateOptions{DryRun: true})
+	assert.IsType(t, &ErrConflict{}, err)
+	_, err = shortner.CreateShortPath(ctx, "in valid", "https://example.org", CreateOptions{DryRun: true})
+	assert.IsType(t, &ErrValidation{}, err)
+	shortPath, err = shortner.CreateShortPath(ctx, "other", "https://example.com", CreateOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "taken", shortPath)
+}
@@ -2,25 +2,40 @@ package svc
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/thenilesh/url-shortner/metrics"
 	"github.com/thenilesh/url-shortner/store"
 )
 
 type URLShortnerBuilder struct {
-	minLength      int
-	maxLength      int
-	charset        string
-	targetURLStore store.KVStore
-	shortPathStore store.KVStore
-	metrics        metrics.Metrics
+	minLength       int
+	maxLength       int
+	charset         string
+	targetURLStore  store.KVStore
+	shortPathStore  store.KVStore
+	aliasStore      store.KVStore
+	metrics         metrics.Metrics
+	sequence        store.Sequence
+	obfuscate       bool
+	retryBudget     int
+	defaultStyle    string
+	reservedWords   []string
+	blockedWords    []string
+	secureRandom    bool
+	caseInsensitive bool
 }
 
 func NewURLShortnerBuilder() *URLShortnerBuilder {
 	return &URLShortnerBuilder{
-		minLength: 4,
-		maxLength: 7,
-		charset:   "abcdefghijklmnopqrstuvwxyz0123456789",
+		minLength:    4,
+		maxLength:    7,
+		charset:      "abcdefghijklmnopqrstuvwxyz0123456789",
+		retryBudget:  3,
+		defaultStyle: StyleRandom,
+		blockedWords: DefaultBlockedWords(),
 	}
 }
 
@@ -49,11 +64,75 @@ func (b *URLShortnerBuilder) SetShortPathStore(store store.KVStore) *URLShortner
 	return b
 }
 
+// SetAliasStore sets the store of aliases indexed by IndexAliases, required
+// in case-insensitive mode
+func (b *URLShortnerBuilder) SetAliasStore(store store.KVStore) *URLShortnerBuilder {
+	b.aliasStore = store
+	return b
+}
+
 func (b *URLShortnerBuilder) SetMetrics(metrics metrics.Metrics) *URLShortnerBuilder {
 	b.metrics = metrics
 	return b
 }
 
+// SetCounterIDs generates shortPaths from numbers of sequence, encoded in
+// charset, instead of random strings. IDs are at least minLength long and
+// grow beyond maxLength once the shorter ones are used up. When obfuscate is
+// set, consecutive IDs are shuffled so that they are not guessable.
+// It also makes StyleCounter the default style.
+func (b *URLShortnerBuilder) SetCounterIDs(sequence store.Sequence, obfuscate bool) *URLShortnerBuilder {
+	b.sequence = sequence
+	b.obfuscate = obfuscate
+	b.defaultStyle = StyleCounter
+	return b
+}
+
+// SetDefaultStyle sets the style of shortPaths generated for requests which
+// don't ask for a style, StyleRandom by default
+func (b *URLShortnerBuilder) SetDefaultStyle(style string) *URLShortnerBuilder {
+	b.defaultStyle = style
+	return b
+}
+
+// SetRetryBudget sets the number of generated shortPaths tried before
+// giving up on a create request, 3 by default
+func (b *URLShortnerBuilder) SetRetryBudget(retryBudget int) *URLShortnerBuilder {
+	b.retryBudget = retryBudget
+	return b
+}
+
+// SetReservedWords sets shortPaths not allowed, in addition to the first
+// path segments of routes like metrics, api and health. Reserved words are
+// matched case-insensitively against the whole shortPath.
+func (b *URLShortnerBuilder) SetReservedWords(words []string) *URLShortnerBuilder {
+	b.reservedWords = words
+	return b
+}
+
+// SetBlockedWords replaces DefaultBlockedWords. ShortPaths containing any
+// of the words are rejected, generated ones are regenerated.
+func (b *URLShortnerBuilder) SetBlockedWords(words []string) *URLShortnerBuilder {
+	b.blockedWords = words
+	return b
+}
+
+// SetSecureRandom generates random shortPaths with crypto/rand instead of
+// math/rand, so that they can't be predicted
+func (b *URLShortnerBuilder) SetSecureRandom(secure bool) *URLShortnerBuilder {
+	b.secureRandom = secure
+	return b
+}
+
+// SetCaseInsensitive resolves shortPaths regardless of their case. New
+// shortPaths are stored in lower case, and upper case letters are dropped
+// from charset of generated ones. Mixed-case shortPaths stored earlier
+// resolve in any case through their aliases, see IndexAliases.
+func (b *URLShortnerBuilder) SetCaseInsensitive(caseInsensitive bool) *URLShortnerBuilder {
+	b.caseInsensitive = caseInsensitive
+	return b
+}
+
 func (b *URLShortnerBuilder) Build() (URLShortner, error) {
 	if b.targetURLStore == nil {
 		return nil, errors.New("targetURLStore is nil")
@@ -64,6 +143,9 @@ func (b *URLShortnerBuilder) Build() (URLShortner, error) {
 	if b.metrics == nil {
 		return nil, errors.New("metrics is nil")
 	}
+	if b.caseInsensitive && b.aliasStore == nil {
+		return nil, errors.New("aliasStore is nil in case-insensitive mode")
+	}
 	if b.minLength <= 0 || b.maxLength <= 0 {
 		return nil, errors.New("minLength or maxLength is less than or equal to 0")
 	}
@@ -73,15 +155,70 @@ func (b *URLShortnerBuilder) Build() (URLShortner, error) {
 	if b.minLength > b.maxLength {
 		return nil, errors.New("minLength is greater than maxLength")
 	}
+	if b.retryBudget <= 0 {
+		return nil, errors.New("retryBudget is less than or equal to 0")
+	}
+	if b.charset == "" {
+		return nil, errors.New("charset is empty")
+	}
+	// Generators pick a character of charset with a random byte
+	if len(b.charset) > 256 {
+		return nil, errors.New("charset is longer than 256 characters")
+	}
 	if !isValidPathSegment(b.charset) {
 		return nil, errors.New("charset contains invalid characters")
 	}
+	for i := 0; i < len(b.charset); i++ {
+		if indexOf(b.charset, b.charset[i]) != i {
+			return nil, errors.New("charset must not repeat characters")
+		}
+	}
 
-	randomStrGen := NewRandomStrGen(b.minLength, b.maxLength, b.charset)
+	charset := b.charset
+	if b.caseInsensitive {
+		charset = lowerCharset(charset)
+	}
+	randomStrGen := NewRandomStrGen(b.minLength, b.maxLength, charset)
+	if b.secureRandom {
+		randomStrGen = NewSecureRandomStrGen(b.minLength, b.maxLength, charset)
+	}
+	idStrategies := map[string]IDStrategy{
+		StyleRandom: newRandomIDStrategy(randomStrGen, b.minLength, b.maxLength),
+		// Words are longer than maxLength, hence their length doesn't adapt
+		StyleWords: newRandomIDStrategy(NewWordStrGen(), 0, 0),
+	}
+	if b.sequence != nil {
+		counter, err := newCounterIDStrategy(b.sequence, charset, b.minLength, b.obfuscate)
+		if err != nil {
+			return nil, err
+		}
+		idStrategies[StyleCounter] = counter
+	}
+	if _, ok := idStrategies[b.defaultStyle]; !ok {
+		return nil, fmt.Errorf("unknown default style %s", b.defaultStyle)
+	}
 	return &urlShortner{
-		randomStrGen:   randomStrGen,
-		targetURLStore: b.targetURLStore,
-		shortPathStore: b.shortPathStore,
-		metrics:        b.metrics,
+		idStrategies:    idStrategies,
+		defaultStyle:    b.defaultStyle,
+		filter:          newShortPathFilter(b.reservedWords, b.blockedWords),
+		caseInsensitive: b.caseInsensitive,
+		retryBudget:     b.retryBudget,
+		targetURLStore:  b.targetURLStore,
+		shortPathStore:  b.shortPathStore,
+		aliasStore:      b.aliasStore,
+		metrics:         b.metrics,
+		now:             time.Now,
 	}, nil
 }
+
+// lowerCharset lower cases charset, dropping the characters repeated
+// thereby
+func lowerCharset(charset string) string {
+	var sb strings.Builder
+	for _, char := range strings.ToLower(charset) {
+		if !strings.ContainsRune(sb.String(), char) {
+			sb.WriteRune(char)
+		}
+	}
+	return sb.String()
+}
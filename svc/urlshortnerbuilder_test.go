@@ -1,10 +1,12 @@
 package svc
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/thenilesh/url-shortner/mocks"
+	"github.com/thenilesh/url-shortner/store"
 )
 
 func TestURLShortnerBuilder_Build(t *testing.T) {
@@ -25,7 +27,13 @@ func TestURLShortnerBuilder_Build(t *testing.T) {
 				SetShortPathStore(shortPathStore).
 				SetMetrics(metrics),
 			expected: &urlShortner{
-				randomStrGen:   NewRandomStrGen(4, 7, "abcdefghijklmnopqrstuvwxyz0123456789"),
+				idStrategies: map[string]IDStrategy{
+					StyleRandom: newRandomIDStrategy(NewRandomStrGen(4, 7, "abcdefghijklmnopqrstuvwxyz0123456789"), 4, 7),
+					StyleWords:  newRandomIDStrategy(NewWordStrGen(), 0, 0),
+				},
+				defaultStyle:   StyleRandom,
+				filter:         newShortPathFilter(nil, DefaultBlockedWords()),
+				retryBudget:    3,
 				targetURLStore: targetURLStore,
 				shortPathStore: shortPathStore,
 				metrics:        metrics,
@@ -118,6 +126,67 @@ func TestURLShortnerBuilder_Build(t *testing.T) {
 			expected:   nil,
 			errMessage: "charset contains invalid characters",
 		},
+		{
+			name: "empty charset",
+			builder: NewURLShortnerBuilder().
+				SetTargetURLStore(targetURLStore).
+				SetShortPathStore(shortPathStore).
+				SetMetrics(metrics).
+				SetCharset(""),
+			expected:   nil,
+			errMessage: "charset is empty",
+		},
+		{
+			name: "charset with repeated characters",
+			builder: NewURLShortnerBuilder().
+				SetTargetURLStore(targetURLStore).
+				SetShortPathStore(shortPathStore).
+				SetMetrics(metrics).
+				SetCharset("abca"),
+			expected:   nil,
+			errMessage: "charset must not repeat characters",
+		},
+		{
+			name: "oversized charset",
+			builder: NewURLShortnerBuilder().
+				SetTargetURLStore(targetURLStore).
+				SetShortPathStore(shortPathStore).
+				SetMetrics(metrics).
+				SetCharset(strings.Repeat("a", 257)),
+			expected:   nil,
+			errMessage: "charset is longer than 256 characters",
+		},
+		{
+			name: "unknown default style",
+			builder: NewURLShortnerBuilder().
+				SetTargetURLStore(targetURLStore).
+				SetShortPathStore(shortPathStore).
+				SetMetrics(metrics).
+				SetDefaultStyle(StyleCounter),
+			expected:   nil,
+			errMessage: "unknown default style counter",
+		},
+		{
+			name: "zero retryBudget",
+			builder: NewURLShortnerBuilder().
+				SetTargetURLStore(targetURLStore).
+				SetShortPathStore(shortPathStore).
+				SetMetrics(metrics).
+				SetRetryBudget(0),
+			expected:   nil,
+			errMessage: "retryBudget is less than or equal to 0",
+		},
+		{
+			name: "counter IDs with single character charset",
+			builder: NewURLShortnerBuilder().
+				SetTargetURLStore(targetURLStore).
+				SetShortPathStore(shortPathStore).
+				SetMetrics(metrics).
+				SetCharset("a").
+				SetCounterIDs(store.NewGoMapSequence(), false),
+			expected:   nil,
+			errMessage: "charset must have at least 2 characters",
+		},
 	}
 
 	for _, tt := range tests {
@@ -131,3 +200,32 @@ func TestURLShortnerBuilder_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestURLShortnerBuilder_SetSecureRandom(t *testing.T) {
+	shortner, err := NewURLShortnerBuilder().
+		SetTargetURLStore(new(mocks.KVStore)).
+		SetShortPathStore(new(mocks.KVStore)).
+		SetMetrics(new(mocks.Metrics)).
+		SetSecureRandom(true).
+		Build()
+	assert.NoError(t, err)
+	strategy := shortner.(*urlShortner).idStrategies[StyleRandom].(*randomIDStrategy)
+	assert.IsType(t, &secureStrGen{}, strategy.randomStrGen)
+}
+
+func TestURLShortnerBuilder_SetCaseInsensitive(t *testing.T) {
+	builder := NewURLShortnerBuilder().
+		SetTargetURLStore(new(mocks.KVStore)).
+		SetShortPathStore(new(mocks.KVStore)).
+		SetMetrics(new(mocks.Metrics)).
+		SetCharset("aAbB0").
+		SetCaseInsensitive(true).
+		SetSecureRandom(false)
+	_, err := builder.Build()
+	assert.EqualError(t, err, "aliasStore is nil in case-insensitive mode")
+
+	shortner, err := builder.SetAliasStore(new(mocks.KVStore)).Build()
+	assert.NoError(t, err)
+	strategy := shortner.(*urlShortner).idStrategies[StyleRandom].(*randomIDStrategy)
+	assert.Equal(t, "ab0", strategy.randomStrGen.(*randomStrGen).charset)
+}
@@ -0,0 +1,61 @@
+package svc
+
+import (
+	_ "embed"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	//go:embed wordlists/adjectives.txt
+	adjectivesList string
+	//go:embed wordlists/nouns.txt
+	nounsList string
+)
+
+// wordStrGen generates memorable strings like brave-otter-42, made of an
+// adjective, a noun and a two digit number
+type wordStrGen struct {
+	adjectives []string
+	nouns      []string
+	// Guards rand, which is not safe for concurrent use
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func NewWordStrGen() RandomStrGen {
+	return &wordStrGen{
+		adjectives: strings.Fields(adjectivesList),
+		nouns:      strings.Fields(nounsList),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (w *wordStrGen) Generate() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.generate()
+}
+
+func (w *wordStrGen) generate() string {
+	return fmt.Sprintf("%s-%s-%d", w.pick(w.adjectives), w.pick(w.nouns), 10+w.rand.Intn(90))
+}
+
+// GenerateAtLeast prepends adjectives until the string is at least
+// minLength long
+func (w *wordStrGen) GenerateAtLeast(minLength int) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.generate()
+	for len(s) < minLength {
+		s = w.pick(w.adjectives) + "-" + s
+	}
+	return s
+}
+
+func (w *wordStrGen) pick(words []string) string {
+	return words[w.rand.Intn(len(words))]
+}
@@ -0,0 +1,46 @@
+package svc
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWordStrGen_Generate(t *testing.T) {
+	r := NewWordStrGen()
+	pattern := regexp.MustCompile(`^[a-z]+-[a-z]+-[1-9][0-9]$`)
+	for i := 0; i < 100; i++ {
+		got := r.Generate()
+		if !pattern.MatchString(got) {
+			t.Errorf("Generate() = %q, want adjective-noun-number", got)
+		}
+		if err := validateShortPath(got); err != nil {
+			t.Errorf("Generate() = %q, is not a valid short path: %v", got, err)
+		}
+	}
+}
+
+func TestWordStrGen_GenerateAtLeast(t *testing.T) {
+	r := NewWordStrGen()
+	got := r.GenerateAtLeast(30)
+	if len(got) < 30 {
+		t.Errorf("GenerateAtLeast(30) = %q, want string of length at least 30", got)
+	}
+	if !isValidPathSegment(got) {
+		t.Errorf("GenerateAtLeast(30) = %q, contains invalid characters", got)
+	}
+}
+
+func TestWordlists(t *testing.T) {
+	for name, list := range map[string]string{"adjectives": adjectivesList, "nouns": nounsList} {
+		words := strings.Fields(list)
+		if len(words) < 100 {
+			t.Errorf("Expected at least 100 %s, got %d", name, len(words))
+		}
+		for _, word := range words {
+			if !isValidPathSegment(word) || strings.ContainsAny(word, "-_") {
+				t.Errorf("%s contains invalid word %q", name, word)
+			}
+		}
+	}
+}
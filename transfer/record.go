@@ -0,0 +1,174 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// csvHeader is the first row of CSV files, it is optional on import
+var csvHeader = []string{"short_path", "target_url"}
+
+// Record is a shortPath along with its targetURL
+type Record struct {
+	ShortPath string `json:"short_path"`
+	TargetURL string `json:"target_url"`
+}
+
+// RecordReader reads Records one at a time
+type RecordReader interface {
+	// Read returns the next Record along with its line number, io.EOF once
+	// there are no more. Error of a malformed Record is of type
+	// *ErrMalformed, reading may continue after it.
+	Read() (Record, int, error)
+}
+
+// RecordWriter writes Records one at a time
+type RecordWriter interface {
+	Write(record Record) error
+	// Flush writes any buffered Records
+	Flush() error
+}
+
+// ErrMalformed is returned by RecordReader for a Record that can't be parsed
+type ErrMalformed struct {
+	msg string
+}
+
+func (e *ErrMalformed) Error() string {
+	return e.msg
+}
+
+// NewRecordReader reads Records of format from r
+func NewRecordReader(r io.Reader, format string) (RecordReader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return &csvRecordReader{reader: reader}, nil
+	case FormatJSONL:
+		return &jsonlRecordReader{scanner: bufio.NewScanner(r)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// NewRecordWriter writes Records in format to w
+func NewRecordWriter(w io.Writer, format string) (RecordWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvRecordWriter{writer: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		writer := bufio.NewWriter(w)
+		return &jsonlRecordWriter{writer: writer, encoder: json.NewEncoder(writer)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// csvRecordReader reads rows of short_path and target_url, extra columns
+// are ignored
+type csvRecordReader struct {
+	reader *csv.Reader
+	rows   int
+}
+
+func (c *csvRecordReader) Read() (Record, int, error) {
+	for {
+		row, err := c.reader.Read()
+		if err == io.EOF {
+			return Record{}, 0, err
+		}
+		c.rows++
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				return Record{}, parseErr.StartLine, &ErrMalformed{msg: err.Error()}
+			}
+			return Record{}, 0, err
+		}
+		line, _ := c.reader.FieldPos(0)
+		if c.rows == 1 && len(row) >= 2 && strings.EqualFold(row[0], csvHeader[0]) && strings.EqualFold(row[1], csvHeader[1]) {
+			continue
+		}
+		if len(row) < 2 {
+			return Record{}, line, &ErrMalformed{msg: "expected short_path and target_url"}
+		}
+		return Record{ShortPath: row[0], TargetURL: row[1]}, line, nil
+	}
+}
+
+// jsonlRecordReader reads a JSON object per line, blank lines are skipped
+type jsonlRecordReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlRecordReader) Read() (Record, int, error) {
+	for j.scanner.Scan() {
+		j.line++
+		text := strings.TrimSpace(j.scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return Record{}, j.line, &ErrMalformed{msg: err.Error()}
+		}
+		return record, j.line, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return Record{}, j.line, err
+	}
+	return Record{}, 0, io.EOF
+}
+
+type csvRecordWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvRecordWriter) Write(record Record) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.writer.Write([]string{record.ShortPath, record.TargetURL})
+}
+
+// Flush writes the header even if there are no Records
+func (c *csvRecordWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvRecordWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.writer.Write(csvHeader)
+}
+
+type jsonlRecordWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (j *jsonlRecordWriter) Write(record Record) error {
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonlRecordWriter) Flush() error {
+	return j.writer.Flush()
+}
@@ -0,0 +1,93 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, r RecordReader) ([]Record, []int, []error) {
+	records := make([]Record, 0)
+	lines := make([]int, 0)
+	errs := make([]error, 0)
+	for {
+		record, line, err := r.Read()
+		if err == io.EOF {
+			return records, lines, errs
+		}
+		records = append(records, record)
+		lines = append(lines, line)
+		errs = append(errs, err)
+	}
+}
+
+func TestRecordReader_csv(t *testing.T) {
+	input := "short_path,target_url\n" +
+		"docs,https://example.com/docs\n" +
+		"\n" +
+		"lonely\n" +
+		", https://example.com/generated,extra\n"
+	r, err := NewRecordReader(strings.NewReader(input), FormatCSV)
+	assert.NoError(t, err)
+
+	records, lines, errs := readAll(t, r)
+	assert.Equal(t, []Record{
+		{ShortPath: "docs", TargetURL: "https://example.com/docs"},
+		{},
+		{ShortPath: "", TargetURL: "https://example.com/generated"},
+	}, records)
+	assert.Equal(t, []int{2, 4, 5}, lines)
+	assert.NoError(t, errs[0])
+	assert.IsType(t, &ErrMalformed{}, errs[1])
+	assert.NoError(t, errs[2])
+}
+
+func TestRecordReader_jsonl(t *testing.T) {
+	input := `{"short_path": "docs", "target_url": "https://example.com/docs"}
+
+not json
+{"target_url": "https://example.com/generated"}
+`
+	r, err := NewRecordReader(strings.NewReader(input), FormatJSONL)
+	assert.NoError(t, err)
+
+	records, lines, errs := readAll(t, r)
+	assert.Equal(t, []Record{
+		{ShortPath: "docs", TargetURL: "https://example.com/docs"},
+		{},
+		{TargetURL: "https://example.com/generated"},
+	}, records)
+	assert.Equal(t, []int{1, 3, 4}, lines)
+	assert.NoError(t, errs[0])
+	assert.IsType(t, &ErrMalformed{}, errs[1])
+	assert.NoError(t, errs[2])
+}
+
+func TestRecordWriter(t *testing.T) {
+	records := []Record{
+		{ShortPath: "docs", TargetURL: "https://example.com/docs"},
+		{ShortPath: "q", TargetURL: "https://example.com/?a=1,2"},
+	}
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		var buf bytes.Buffer
+		w, err := NewRecordWriter(&buf, format)
+		assert.NoError(t, err)
+		for _, record := range records {
+			assert.NoError(t, w.Write(record))
+		}
+		assert.NoError(t, w.Flush())
+
+		// Written records read back as is
+		r, err := NewRecordReader(&buf, format)
+		assert.NoError(t, err)
+		got, _, errs := readAll(t, r)
+		assert.Equal(t, records, got, format)
+		assert.Equal(t, []error{nil, nil}, errs, format)
+	}
+
+	_, err := NewRecordWriter(io.Discard, "xml")
+	assert.Error(t, err)
+}
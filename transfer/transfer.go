@@ -0,0 +1,126 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/thenilesh/url-shortner/svc"
+)
+
+// exportPageSize is the number of ShortURLs listed at a time on export
+const exportPageSize = 100
+
+// Status of importing a Record
+const (
+	// StatusCreated on dry run means the Record would be created
+	StatusCreated = "created"
+	// StatusExists means the Record is already shortened as is. Records
+	// without shortPath are reported created even if already shortened.
+	StatusExists = "exists"
+	// StatusConflict means either the shortPath points to a different
+	// targetURL, or the targetURL is shortened with a different shortPath
+	StatusConflict = "conflict"
+	StatusInvalid  = "invalid"
+	StatusFailed   = "failed"
+)
+
+// ImportResult is the result of importing the Record at Line
+type ImportResult struct {
+	Line   int
+	Record Record
+	Status string
+	// ShortPath the targetURL is shortened with, empty on errors and on dry
+	// run of Records without shortPath
+	ShortPath string
+	Err       error
+}
+
+// ImportSummary counts ImportResults by Status
+type ImportSummary map[string]int
+
+func (s ImportSummary) String() string {
+	return fmt.Sprintf("created:%d exists:%d conflict:%d invalid:%d failed:%d",
+		s[StatusCreated], s[StatusExists], s[StatusConflict], s[StatusInvalid], s[StatusFailed])
+}
+
+// Import creates the Records read from r through urlShortner, reporting the
+// result of every Record. A Record without shortPath gets a generated one.
+// On dry run the Records are only validated and checked for conflicts with
+// the stored ones, not with each other. It stops only if r fails.
+func Import(ctx context.Context, urlShortner svc.URLShortner, r RecordReader, dryRun bool, report func(ImportResult)) (ImportSummary, error) {
+	summary := make(ImportSummary)
+	for {
+		record, line, err := r.Read()
+		if err == io.EOF {
+			return summary, nil
+		}
+		result := ImportResult{Line: line, Record: record}
+		if err != nil {
+			if _, ok := err.(*ErrMalformed); !ok {
+				return summary, err
+			}
+			result.Status, result.Err = StatusInvalid, err
+		} else {
+			result = importRecord(ctx, urlShortner, result, dryRun)
+		}
+		summary[result.Status]++
+		report(result)
+	}
+}
+
+func importRecord(ctx context.Context, urlShortner svc.URLShortner, result ImportResult, dryRun bool) ImportResult {
+	record := result.Record
+	if record.ShortPath != "" {
+		targetURL, err := urlShortner.GetTargetURL(ctx, record.ShortPath)
+		if err == nil && targetURL == strings.TrimSuffix(record.TargetURL, "/") {
+			result.Status, result.ShortPath = StatusExists, record.ShortPath
+			return result
+		}
+	}
+	shortPath, err := urlShortner.CreateShortPath(ctx, record.ShortPath, record.TargetURL, svc.CreateOptions{DryRun: dryRun})
+	switch err.(type) {
+	case nil:
+	case *svc.ErrValidation:
+		result.Status, result.Err = StatusInvalid, err
+		return result
+	case *svc.ErrConflict:
+		result.Status, result.Err = StatusConflict, err
+		return result
+	default:
+		result.Status, result.Err = StatusFailed, err
+		return result
+	}
+	// shortPath differing only in case is the canonical one of
+	// case-insensitive mode, otherwise targetURL was already shortened
+	if record.ShortPath != "" && !strings.EqualFold(shortPath, record.ShortPath) {
+		result.Status = StatusConflict
+		result.Err = fmt.Errorf("target_url is already shortened as %s", shortPath)
+		return result
+	}
+	result.Status, result.ShortPath = StatusCreated, shortPath
+	return result
+}
+
+// Export writes all the enabled shortPaths to w
+func Export(ctx context.Context, urlShortner svc.URLShortner, w RecordWriter) (int, error) {
+	exported := 0
+	opts := svc.ListOptions{Limit: exportPageSize}
+	for {
+		page, err := urlShortner.ListShortURLs(ctx, opts)
+		if err != nil {
+			return exported, err
+		}
+		for _, shortURL := range page.ShortURLs {
+			if err := w.Write(Record{ShortPath: shortURL.ShortPath, TargetURL: shortURL.TargetURL}); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+		if page.NextCursor == "" {
+			return exported, w.Flush()
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,117 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/thenilesh/url-shortner/metrics"
+	"github.com/thenilesh/url-shortner/mocks"
+	"github.com/thenilesh/url-shortner/store"
+	"github.com/thenilesh/url-shortner/svc"
+)
+
+func newTestURLShortner(t *testing.T) svc.URLShortner {
+	m := new(mocks.Metrics)
+	collector := new(mocks.Collector)
+	collector.On("Inc", mock.Anything)
+	clickTracker := new(mocks.ClickTracker)
+	clickTracker.On("DeleteClickStats", mock.Anything, mock.Anything).Return(nil)
+	clickTracker.On("GetClickStats", mock.Anything, mock.Anything).Return(metrics.ClickStats{}, nil)
+	m.On("GetCollector", metrics.DomainShortens).Return(collector, nil)
+	m.On("GetCounter", metrics.ShortensTotal).Return(metrics.NewCounterVec(metrics.ShortensTotal, ""))
+	m.On("GetCounter", metrics.ShortPathAttemptsTotal).Return(metrics.NewCounterVec(metrics.ShortPathAttemptsTotal, "", "result"))
+	m.On("GetClickTracker").Return(clickTracker)
+	urlShortner, err := svc.NewURLShortnerBuilder().
+		SetTargetURLStore(store.NewGoMapStore()).
+		SetShortPathStore(store.NewGoMapStore()).
+		SetMetrics(m).
+		Build()
+	assert.NoError(t, err)
+	return urlShortner
+}
+
+const importInput = `short_path,target_url
+docs,https://example.com/docs
+blog,https://example.com/blog/
+taken,https://example.com/other
+again,https://example.com/existing
+in valid,https://example.com/invalid
+docs,https://example.com/docs
+`
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	urlShortner := newTestURLShortner(t)
+	_, err := urlShortner.CreateShortPath(ctx, "taken", "https://example.com/taken", svc.CreateOptions{})
+	assert.NoError(t, err)
+	_, err = urlShortner.CreateShortPath(ctx, "existing", "https://example.com/existing", svc.CreateOptions{})
+	assert.NoError(t, err)
+
+	r, err := NewRecordReader(strings.NewReader(importInput), FormatCSV)
+	assert.NoError(t, err)
+	results := make([]ImportResult, 0)
+	summary, err := Import(ctx, urlShortner, r, false, func(result ImportResult) {
+		results = append(results, result)
+	})
+	assert.NoError(t, err)
+
+	statuses := make([]string, 0)
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+	}
+	assert.Equal(t, []string{StatusCreated, StatusCreated, StatusConflict, StatusConflict, StatusInvalid, StatusExists}, statuses)
+	assert.Equal(t, 4, results[2].Line)
+	assert.EqualError(t, results[3].Err, "target_url is already shortened as existing")
+	assert.Equal(t, "created:2 exists:1 conflict:2 invalid:1 failed:0", summary.String())
+
+	targetURL, err := urlShortner.GetTargetURL(ctx, "blog")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/blog", targetURL)
+}
+
+func TestImport_dryRun(t *testing.T) {
+	ctx := context.Background()
+	urlShortner := newTestURLShortner(t)
+	_, err := urlShortner.CreateShortPath(ctx, "taken", "https://example.com/taken", svc.CreateOptions{})
+	assert.NoError(t, err)
+	_, err = urlShortner.CreateShortPath(ctx, "existing", "https://example.com/existing", svc.CreateOptions{})
+	assert.NoError(t, err)
+
+	r, err := NewRecordReader(strings.NewReader(importInput), FormatCSV)
+	assert.NoError(t, err)
+	summary, err := Import(ctx, urlShortner, r, true, func(ImportResult) {})
+	assert.NoError(t, err)
+	// Duplicate docs is not detected on dry run
+	assert.Equal(t, "created:3 exists:0 conflict:2 invalid:1 failed:0", summary.String())
+
+	_, err = urlShortner.GetTargetURL(ctx, "docs")
+	assert.IsType(t, &svc.ErrNotFound{}, err)
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	urlShortner := newTestURLShortner(t)
+	for i := 0; i < exportPageSize+1; i++ {
+		_, err := urlShortner.CreateShortPath(ctx, "", "https://example.com/"+strings.Repeat("a", i+1), svc.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewRecordWriter(&buf, FormatJSONL)
+	assert.NoError(t, err)
+	exported, err := Export(ctx, urlShortner, w)
+	assert.NoError(t, err)
+	assert.Equal(t, exportPageSize+1, exported)
+
+	// Export of one imports into another as is
+	other := newTestURLShortner(t)
+	r, err := NewRecordReader(&buf, FormatJSONL)
+	assert.NoError(t, err)
+	summary, err := Import(ctx, other, r, false, func(ImportResult) {})
+	assert.NoError(t, err)
+	assert.Equal(t, exportPageSize+1, summary[StatusCreated])
+}